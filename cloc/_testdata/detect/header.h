@@ -0,0 +1,2 @@
+// -*- C++ -*-
+class Foo {};
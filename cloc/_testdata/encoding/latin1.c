@@ -0,0 +1,10 @@
+// Copyright  2015
+#include <stdio.h>
+
+/* Block
+   comment */
+int main() {
+    {
+        printf("hllo // not a comment");
+    }
+}
@@ -0,0 +1,10 @@
+﻿// Copyright © 2015
+using System;
+
+/* Block
+   comment */
+class Program {
+    static void Main() {
+        Console.WriteLine("héllo // not a comment 😀");
+    }
+}
@@ -0,0 +1,7 @@
+{- A nested {- block -} comment,
+   which is still a comment here. -}
+module Main where
+
+-- | Entry point.
+main :: IO ()
+main = putStrLn "{- not a comment -}"
@@ -0,0 +1 @@
+!function(){"use strict";var a=1;console.log(a)}();
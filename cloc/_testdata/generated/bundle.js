@@ -0,0 +1,2 @@
+/*! bundle v1.0.0 */
+function f0(a,b){return a+b*0}var v0=f0(1,2);function f1(a,b){return a+b*1}var v1=f1(1,2);function f2(a,b){return a+b*2}var v2=f2(1,2);function f3(a,b){return a+b*3}var v3=f3(1,2);function f4(a,b){return a+b*4}var v4=f4(1,2);function f5(a,b){return a+b*5}var v5=f5(1,2);function f6(a,b){return a+b*6}var v6=f6(1,2);function f7(a,b){return a+b*7}var v7=f7(1,2);function f8(a,b){return a+b*8}var v8=f8(1,2);function f9(a,b){return a+b*9}var v9=f9(1,2);function f10(a,b){return a+b*10}var v10=f10(1,2);function f11(a,b){return a+b*11}var v11=f11(1,2);function f12(a,b){return a+b*12}var v12=f12(1,2);function f13(a,b){return a+b*13}var v13=f13(1,2);function f14(a,b){return a+b*14}var v14=f14(1,2);function f15(a,b){return a+b*15}var v15=f15(1,2);function f16(a,b){return a+b*16}var v16=f16(1,2);function f17(a,b){return a+b*17}var v17=f17(1,2);function f18(a,b){return a+b*18}var v18=f18(1,2);function f19(a,b){return a+b*19}var v19=f19(1,2);function f20(a,b){return a+b*20}var v20=f20(1,2);function f21(a,b){return a+b*21}var v21=f21(1,2);function f22(a,b){return a+b*22}var v22=f22(1,2);function f23(a,b){return a+b*23}var v23=f23(1,2);function f24(a,b){return a+b*24}var v24=f24(1,2);function f25(a,b){return a+b*25}var v25=f25(1,2);function f26(a,b){return a+b*26}var v26=f26(1,2);function f27(a,b){return a+b*27}var v27=f27(1,2);function f28(a,b){return a+b*28}var v28=f28(1,2);function f29(a,b){return a+b*29}var v29=f29(1,2);function f30(a,b){return a+b*30}var v30=f30(1,2);function f31(a,b){return a+b*31}var v31=f31(1,2);function f32(a,b){return a+b*32}var v32=f32(1,2);function f33(a,b){return a+b*33}var v33=f33(1,2);function f34(a,b){return a+b*34}var v34=f34(1,2);function f35(a,b){return a+b*35}var v35=f35(1,2);function f36(a,b){return a+b*36}var v36=f36(1,2);function f37(a,b){return a+b*37}var v37=f37(1,2);function f38(a,b){return a+b*38}var v38=f38(1,2);function f39(a,b){return a+b*39}var v39=f39(1,2);
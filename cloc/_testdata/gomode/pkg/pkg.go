@@ -0,0 +1,24 @@
+// Package pkg is used to test the Go mode.
+package pkg
+
+import "fmt"
+
+// Exported constants.
+const (
+	A = 1
+	b = 2
+)
+
+var Version = "1.0"
+
+// T is a type.
+type T struct{}
+
+type t int
+
+// String returns a string.
+func (T) String() string {
+	return fmt.Sprint(A + b)
+}
+
+func helper() {}
@@ -0,0 +1,9 @@
+package pkg
+
+import "testing"
+
+func TestString(t *testing.T) {
+	if (T{}).String() != "3" {
+		t.Fatal("unexpected string")
+	}
+}
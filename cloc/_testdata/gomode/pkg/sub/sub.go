@@ -0,0 +1,4 @@
+package sub
+
+// Sub subtracts b from a.
+func Sub(a, b int) int { return a - b }
@@ -0,0 +1,5 @@
+package sub_test
+
+import "testing"
+
+func TestSub(t *testing.T) {}
@@ -0,0 +1,8 @@
+program Hello;
+{ Comment (* with the other style *) inside }
+(* Multi
+   line *)
+begin
+  // Line comment
+  writeln('{ not a comment }');
+end.
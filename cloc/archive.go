@@ -0,0 +1,219 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+// The separator between the path of an archive and the path of a file inside
+// it, e.g. "archive.zip!/inner/path.go".
+const archiveSeparator = "!/"
+
+// Kinds of archives, by extension, see archiveKind.
+const (
+	archiveZip   = ".zip"
+	archiveTar   = ".tar"
+	archiveTarGz = ".tar.gz"
+	archiveTgz   = ".tgz"
+	archiveTarBz = ".tar.bz2"
+)
+
+// The maximum size of a single file inside an archive, files are read into
+// memory to be counted. Larger files are reported as errors, for testing it
+// can be overwritten.
+var maxArchiveFileSize int64 = 64 << 20
+
+// ArchiveKind returns the kind of archive based on the extension of the
+// path, or an empty string if it's not an archive.
+func archiveKind(p string) string {
+	lower := strings.ToLower(p)
+	for _, ext := range []string{archiveZip, archiveTarGz, archiveTgz, archiveTarBz, archiveTar} {
+		if strings.HasSuffix(lower, ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+// CountArchive counts the number of blank, comment and code lines in all
+// files in the zip or tar archive at path, without unpacking it to disk. The
+// files are detected and counted like countFile does, using the path of the
+// archive followed by the path inside the archive, e.g.
+// "archive.zip!/inner/path.go".
+//
+// The -exclude-dir, -exclude and -max-depth flags are respected, like in
+// countRev ignore files are not read. Symbolic links and archives inside the
+// archive are not counted. Using -dedup the files are added to duplicates.
+//
+// Files that can't be read or counted, or are larger than maxArchiveFileSize,
+// are returned as loc.Errors, the counts hold all other files. Only if the
+// archive itself is broken the counting stops.
+func countArchive(archive string) (loc.Counts, error) {
+	// The root of the files, for treeExcluded.
+	root := strings.TrimSuffix(archive+archiveSeparator, "/")
+
+	c := loc.Counts{}
+	var errs loc.Errors
+	err := walkArchive(archive, func(name string, r io.Reader) error {
+		p := archive + archiveSeparator + name
+		if treeExcluded(root, p) {
+			return nil
+		}
+
+		content, err := ioutil.ReadAll(io.LimitReader(r, maxArchiveFileSize+1))
+		if err != nil {
+			// If the archive is broken walkArchive fails on the next file.
+			errs.Add(fmt.Errorf("%s: %s", p, err))
+			return nil
+		} else if int64(len(content)) > maxArchiveFileSize {
+			errs.Add(fmt.Errorf("%s: larger than the maximum of %d bytes", p,
+				maxArchiveFileSize))
+			return nil
+		}
+
+		var fileCounts loc.Counts
+		var hash string
+		src := bytes.NewReader(content)
+		if duplicates != nil {
+			fileCounts, hash, err = countSourceHash(p, src, src.Size())
+		} else {
+			fileCounts, err = countSource(p, src, src.Size())
+		}
+		if err != nil {
+			errs.Add(fmt.Errorf("%s: %s", p, err))
+			return nil
+		}
+		c.Add(fileCounts)
+		if duplicates != nil {
+			duplicates.add(archive, p, hash, fileCounts)
+		}
+		return nil
+	})
+	if err != nil {
+		errs.Add(fmt.Errorf("%s: %s", archive, err))
+	}
+	if budgetDirs != nil {
+		budgetDirs.add(archive, c)
+	}
+	return c, errs.Err()
+}
+
+// WalkArchive calls fn for every regular file in the archive, with the
+// cleaned slash separated path inside the archive and a reader of its
+// contents, which is only valid during the call. A file that can't be opened,
+// e.g. a zip file compressed using an unsupported method, is passed with a
+// reader returning the error, like a file that can't be read. An error
+// returned by fn stops the walk.
+func walkArchive(archive string, fn func(name string, r io.Reader) error) error {
+	if archiveKind(archive) == archiveZip {
+		return walkZip(archive, fn)
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	switch archiveKind(archive) {
+	case archiveTarGz, archiveTgz:
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case archiveTarBz:
+		r = bzip2.NewReader(f)
+	}
+	return walkTar(r, fn)
+}
+
+func walkZip(archive string, fn func(name string, r io.Reader) error) error {
+	z, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer z.Close()
+
+	for _, f := range z.File {
+		name, ok := archiveName(f.Name)
+		if !ok || !f.Mode().IsRegular() || archiveKind(name) != "" {
+			continue
+		}
+
+		r, err := f.Open()
+		if err != nil {
+			if err := fn(name, errReader{err}); err != nil {
+				return err
+			}
+			continue
+		}
+		err = fn(name, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ErrReader is a reader that always returns err.
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func walkTar(r io.Reader, fn func(name string, r io.Reader) error) error {
+	t := tar.NewReader(r)
+	for {
+		header, err := t.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		name, ok := archiveName(header.Name)
+		if !ok || !header.FileInfo().Mode().IsRegular() || archiveKind(name) != "" {
+			continue
+		}
+
+		if err := fn(name, t); err != nil {
+			return err
+		}
+	}
+}
+
+// ArchiveName cleans the path of a file inside an archive, removing any
+// leading "/", "./" and "../". It returns false for directories.
+func archiveName(name string) (string, bool) {
+	if strings.HasSuffix(name, "/") {
+		return "", false
+	}
+
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" || name == "." {
+		return "", false
+	}
+	return name, true
+}
@@ -0,0 +1,237 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+// Files in the test archives, in order.
+var archiveFiles = []struct {
+	name, content string
+}{
+	{"project/main.go", "package main\n\n// Comment.\nfunc main() {}\n"},
+	{"./project/lib/lib.c", "int x;\n"},
+	{"project/vendor/v.go", "package v\n"},
+	{"project/README", "Not counted.\n"},
+	{"project/nested.zip", "Not opened.\n"},
+}
+
+// Counts of the test archives, with vendor excluded.
+var archiveCounts = loc.Counts{
+	"go": &loc.Stats{Files: 1, Blank: 1, Comment: 1, Code: 2},
+	"c":  &loc.Stats{Files: 1, Code: 1},
+}
+
+func writeTestZip(t *testing.T, path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	z := zip.NewWriter(f)
+	if _, err := z.Create("project/"); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range archiveFiles {
+		w, err := z.Create(file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, file.content)
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTestTar(t *testing.T, w io.Writer) {
+	tw := tar.NewWriter(w)
+	tw.WriteHeader(&tar.Header{Name: "project/", Typeflag: tar.TypeDir, Mode: 0755})
+	tw.WriteHeader(&tar.Header{Name: "project/link.go", Typeflag: tar.TypeSymlink,
+		Linkname: "main.go"})
+	for _, file := range archiveFiles {
+		err := tw.WriteHeader(&tar.Header{Name: file.name, Typeflag: tar.TypeReg,
+			Mode: 0644, Size: int64(len(file.content))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(tw, file.content)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCountArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeTestZip(t, filepath.Join(dir, "project.zip"))
+
+	tarFile, err := os.Create(filepath.Join(dir, "project.tar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestTar(t, tarFile)
+	tarFile.Close()
+
+	for _, name := range []string{"project.tar.gz", "project.tgz"} {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		gz := gzip.NewWriter(f)
+		writeTestTar(t, gz)
+		gz.Close()
+		f.Close()
+	}
+
+	archives := []string{"project.zip", "project.tar", "project.tar.gz", "project.tgz"}
+	if _, err := exec.LookPath("bzip2"); err == nil {
+		out, err := exec.Command("bzip2", "-k", filepath.Join(dir, "project.tar")).CombinedOutput()
+		if err != nil {
+			t.Fatalf("Error running bzip2: %s: %s", err, out)
+		}
+		archives = append(archives, "project.tar.bz2")
+	}
+
+	defer func(old stringList) { excludeDirs = old }(excludeDirs)
+	excludeDirs = stringList{"vendor"}
+
+	for _, name := range archives {
+		got, err := count(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("Unexpected error counting %s: %s", name, err)
+		} else if !reflect.DeepEqual(got, archiveCounts) {
+			t.Errorf("Expected count(%s) to return %v, got %v", name,
+				archiveCounts, got)
+		}
+	}
+
+	// Paths of files inside the archive.
+	var names []string
+	err = walkArchive(filepath.Join(dir, "project.tar"), func(name string, r io.Reader) error {
+		names = append(names, name)
+		return nil
+	})
+	sort.Strings(names)
+	expected := []string{"project/README", "project/lib/lib.c", "project/main.go",
+		"project/vendor/v.go"}
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	} else if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected walkArchive to return %v, got %v", expected, names)
+	}
+
+	// Corrupt archives are reported with the path of the archive.
+	broken := filepath.Join(dir, "broken.tar.gz")
+	if err := ioutil.WriteFile(broken, []byte("not gzip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := count(broken); err == nil || !strings.Contains(err.Error(), broken) {
+		t.Errorf("Expected an error for %s, got %v", broken, err)
+	}
+}
+
+func TestCountArchiveFileErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "files.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := zip.NewWriter(f)
+	files := []struct {
+		name, content string
+		crc           uint32
+		method        uint16
+	}{
+		{"bad_checksum.go", "package bad\n", 1, zip.Store},
+		{"large.go", "package large\n\nvar x = 1\n", 0, zip.Store},
+		{"lzma.go", "package lzma\n", 0, 14}, // LZMA, not supported.
+		{"small.c", "int x;\n", 0, zip.Store},
+	}
+	for _, file := range files {
+		crc := file.crc
+		if crc == 0 {
+			crc = crc32.ChecksumIEEE([]byte(file.content))
+		}
+		w, err := z.CreateRaw(&zip.FileHeader{Name: file.name, Method: file.method,
+			CRC32: crc, CompressedSize64: uint64(len(file.content)),
+			UncompressedSize64: uint64(len(file.content))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, file.content)
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	defer func(old int64) { maxArchiveFileSize = old }(maxArchiveFileSize)
+	maxArchiveFileSize = 16
+
+	// The file with the wrong checksum, the large file and the file that
+	// can't be opened fail, but the other files are still counted.
+	got, err := count(path)
+	expected := loc.Counts{"c": &loc.Stats{Files: 1, Code: 1}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected count to return %v, got %v", expected, got)
+	}
+	if errs, ok := err.(loc.Errors); !ok || len(errs) != 3 ||
+		!strings.Contains(errs[0].Error(), "bad_checksum.go") ||
+		!strings.Contains(errs[1].Error(), "large.go: larger than the maximum") ||
+		!strings.Contains(errs[2].Error(), "lzma.go: zip: unsupported compression algorithm") {
+		t.Errorf("Expected errors for bad_checksum.go, large.go and lzma.go, got %v", err)
+	}
+}
+
+func TestArchiveName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		ok       bool
+	}{
+		{"main.go", "main.go", true},
+		{"./a/b.go", "a/b.go", true},
+		{"/a/b.go", "a/b.go", true},
+		{"../../a.go", "a.go", true},
+		{"a/", "", false},
+		{"./", "", false},
+	}
+
+	for _, test := range tests {
+		got, ok := archiveName(test.name)
+		if got != test.expected || ok != test.ok {
+			t.Errorf("Expected archiveName(%q) to return %q, %v, got %q, %v",
+				test.name, test.expected, test.ok, got, ok)
+		}
+	}
+}
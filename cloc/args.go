@@ -0,0 +1,149 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Usage prints the usage of cloc, followed by all options, it's printed for
+// the -h flag and after invalid flags.
+func usage() {
+	fmt.Fprintf(flags.Output(), `Usage: cloc [options] [path ...]
+
+Counts the blank, comment and code lines of the files and directories, or the
+current directory if no path is given. Zip and tar archives given as path are
+counted like directories, archives found in directories are not opened.
+Options can be given before and after the paths, paths after -- are never
+read as options. Options can start with one or two dashes.
+
+Options:
+`)
+	flags.PrintDefaults()
+}
+
+// ParseArgs parses the command line arguments, without the program name,
+// setting the flags. Unlike the flag package flags can also follow the
+// paths, it returns the paths in order.
+func parseArgs(args []string) ([]string, error) {
+	var paths []string
+	for len(args) != 0 {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+
+		rest := flags.Args()
+		if n := len(args) - len(rest); n != 0 && args[n-1] == "--" {
+			// All arguments after -- are paths.
+			return append(paths, rest...), nil
+		} else if len(rest) == 0 {
+			break
+		}
+
+		paths = append(paths, rest[0])
+		args = rest[1:]
+	}
+	return paths, nil
+}
+
+// GetFiles returns the files and directories to count: the paths given as
+// arguments followed by the paths read from the file set by the -files-from
+// flag, see readFileList. If neither is given it defaults to the current
+// directory.
+func getFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		if path != "" {
+			files = append(files, filepath.Clean(path))
+		}
+	}
+
+	if filesFrom != "" {
+		var r io.Reader = os.Stdin
+		if filesFrom != "-" {
+			f, err := os.Open(filesFrom)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			r = f
+		}
+
+		list, err := readFileList(r)
+		if err != nil {
+			return nil, err
+		}
+		return append(files, list...), nil
+	}
+
+	// Default to counting the code in the current diretory.
+	if len(files) == 0 {
+		files = []string{"./"}
+	}
+	return files, nil
+}
+
+// ReadFileList reads a list of paths separated by NUL bytes, e.g. the output
+// of git ls-files -z, or if the list has no NUL bytes separated by new lines.
+// Empty paths are skipped.
+func readFileList(r io.Reader) ([]string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sep := []byte("\n")
+	if bytes.IndexByte(data, 0) != -1 {
+		sep = []byte{0}
+	}
+
+	var files []string
+	for _, path := range bytes.Split(data, sep) {
+		if sep[0] == '\n' {
+			path = bytes.TrimSuffix(path, []byte("\r"))
+		}
+
+		if len(path) != 0 {
+			files = append(files, filepath.Clean(string(path)))
+		}
+	}
+	return files, nil
+}
+
+// MainStdin is main for the -stdin-lang flag, it counts the contents of stdin
+// as a single file in the language, reported with the path "-".
+func mainStdin(write func(io.Writer, report) error) {
+	if _, lang := counter.Languages.ByAlias(stdinLang); lang == nil {
+		fmt.Fprintf(os.Stderr, "Unknown language %s.\n", stdinLang)
+		exit(exitError)
+		return
+	}
+
+	content, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading stdin: %s.\n", err)
+		exit(exitError)
+		return
+	}
+
+	result, err := counter.CountAs("-", stdinLang, bytes.NewReader(content))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error counting stdin: %s.\n", err)
+		exit(exitError)
+		return
+	}
+
+	var r report
+	r.add("-", result.Counts)
+	if err := write(os.Stdout, r); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %s.\n", err)
+		exit(exitError)
+	}
+}
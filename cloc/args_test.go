@@ -0,0 +1,194 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	type test struct {
+		args     []string
+		expected []string
+		format   string
+		maxDepth int
+	}
+
+	tests := []test{
+		{nil, nil, "table", -1},
+		{[]string{"test"}, []string{"test"}, "table", -1},
+		{[]string{"test", "", "test.go"}, []string{"test", "", "test.go"}, "table", -1},
+		{[]string{"-f", "json", "test"}, []string{"test"}, "json", -1},
+		{[]string{"--format=csv", "test"}, []string{"test"}, "csv", -1},
+		{[]string{"test", "-max-depth", "2", "test.go"}, []string{"test", "test.go"}, "table", 2},
+		{[]string{"test", "test.go", "-f", "yaml"}, []string{"test", "test.go"}, "yaml", -1},
+		{[]string{"-", "test"}, []string{"-", "test"}, "table", -1},
+		{[]string{"--", "-f", "test"}, []string{"-f", "test"}, "table", -1},
+		{[]string{"test", "--", "-max-depth", "2"}, []string{"test", "-max-depth", "2"}, "table", -1},
+	}
+
+	defer func() { format, maxDepth = "table", -1 }()
+
+	for _, test := range tests {
+		format, maxDepth = "table", -1
+		got, err := parseArgs(test.args)
+		if err != nil {
+			t.Errorf("Unexpected error parsing %v: %s", test.args, err)
+		} else if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Expected parseArgs(%v) to return %v, got %v", test.args,
+				test.expected, got)
+		} else if format != test.format || maxDepth != test.maxDepth {
+			t.Errorf("Expected parseArgs(%v) to set -f %s and -max-depth %d, got %s and %d",
+				test.args, test.format, test.maxDepth, format, maxDepth)
+		}
+	}
+
+	flags.SetOutput(ioutil.Discard)
+	defer flags.SetOutput(nil)
+	if _, err := parseArgs([]string{"test", "-v"}); err == nil {
+		t.Error("Expected an error for an unknown flag")
+	}
+}
+
+func TestGetFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	list := filepath.Join(dir, "list")
+	if err := ioutil.WriteFile(list, []byte("a.go\r\n\nsub/../b.go\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty")
+	if err := ioutil.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	type test struct {
+		paths     []string
+		filesFrom string
+		expected  []string
+	}
+
+	tests := []test{
+		{nil, "", []string{"./"}},
+		{[]string{"", "test/"}, "", []string{"test"}},
+		{[]string{"test"}, list, []string{"test", "a.go", "b.go"}},
+		// An empty list counts nothing, not the current directory.
+		{nil, empty, nil},
+	}
+
+	defer func() { filesFrom = "" }()
+	for _, test := range tests {
+		filesFrom = test.filesFrom
+		got, err := getFiles(test.paths)
+		if err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		} else if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Expected getFiles(%v) with -files-from %q to return %v, got %v",
+				test.paths, test.filesFrom, test.expected, got)
+		}
+	}
+
+	filesFrom = filepath.Join(dir, "missing")
+	if _, err := getFiles(nil); err == nil {
+		t.Error("Expected an error for a missing file list")
+	}
+}
+
+func TestReadFileList(t *testing.T) {
+	type test struct {
+		input    string
+		expected []string
+	}
+
+	tests := []test{
+		{"", nil},
+		{"a.go\nb c.go\n", []string{"a.go", "b c.go"}},
+		{"a.go\r\nb.go", []string{"a.go", "b.go"}},
+		{"a.go\x00new\nline.go\x00\x00./c.go\x00", []string{"a.go", "new\nline.go", "c.go"}},
+	}
+
+	for _, test := range tests {
+		got, err := readFileList(strings.NewReader(test.input))
+		if err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		} else if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Expected readFileList(%q) to return %v, got %v", test.input,
+				test.expected, got)
+		}
+	}
+}
+
+func TestMainStdin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stdin := filepath.Join(dir, "stdin")
+	if err := ioutil.WriteFile(stdin, []byte("x = 1 # Comment\n\n# y\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldStdin, oldStdout, oldStderr, oldArgs, oldExit := os.Stdin, os.Stdout, os.Stderr, os.Args, exit
+	defer func() {
+		os.Stdin, os.Stdout, os.Stderr, os.Args, exit = oldStdin, oldStdout, oldStderr, oldArgs, oldExit
+		stdinLang, format = "", "table"
+	}()
+
+	run := func(args ...string) (string, int) {
+		in, err := os.Open(stdin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer in.Close()
+
+		out, err := ioutil.TempFile(dir, "stdout")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer out.Close()
+
+		exitCode := 0
+		exit = func(code int) { exitCode = code }
+		os.Stdin, os.Stdout, os.Stderr = in, out, out
+		os.Args = append([]string{""}, args...)
+		stdinLang = ""
+		main()
+
+		output, err := ioutil.ReadFile(out.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(output), exitCode
+	}
+
+	expected := "version,path,language,files,blank,comment,code\n" +
+		"1,-,python,1,1,1,1\n" +
+		"1,-,,1,1,1,1\n" +
+		"1,,python,1,1,1,1\n" +
+		"1,,,1,1,1,1\n"
+	if got, code := run("-stdin-lang", "py", "-f", "csv"); code != 0 || got != expected {
+		t.Errorf("Expected the output %q, got %q (exit code %d)", expected, got, code)
+	}
+
+	if got, code := run("-stdin-lang", "unknown"); code != exitError ||
+		got != "Unknown language unknown.\n" {
+		t.Errorf("Unexpected output %q (exit code %d)", got, code)
+	}
+
+	if _, code := run("-stdin-lang", "go", "file.go"); code != exitError {
+		t.Errorf("Expected exit code %d for a path, got %d", exitError, code)
+	}
+}
@@ -0,0 +1,396 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+// BlameReport holds the number of code lines owned by each author, it's the
+// data written in the blame mode, set using the -blame flag.
+type blameReport struct {
+	Version int           `json:"version"`
+	Rev     string        `json:"rev"`
+	Authors []authorStats `json:"authors"`
+	Total   int           `json:"total"` // Code lines of all authors.
+
+	ownership ownership
+}
+
+// AuthorStats holds the number of code lines owned by a single author, in
+// total, per language and per directory.
+type authorStats struct {
+	Name        string         `json:"name"`
+	Email       string         `json:"email"`
+	Code        int            `json:"code"`
+	Languages   map[string]int `json:"languages"`
+	Directories map[string]int `json:"directories"`
+}
+
+// Author is the author of a line, as reported by git blame, which applies the
+// .mailmap file.
+type author struct {
+	name, email string
+}
+
+func (a author) String() string {
+	return a.name + " <" + a.email + ">"
+}
+
+// Owner is the key under which code lines are counted, a directory is the
+// slash separated directory of the file, as listed by git ls-tree.
+type owner struct {
+	author
+	language, dir string
+}
+
+// Ownership holds the number of code lines per owner.
+type ownership map[owner]int
+
+func (o ownership) add(other ownership) {
+	for key, n := range other {
+		o[key] += n
+	}
+}
+
+// NewBlameReport creates the report of the ownership, sorting the authors by
+// the number of code lines they own.
+func newBlameReport(rev string, o ownership) blameReport {
+	r := blameReport{Version: schemaVersion, Rev: rev, ownership: o}
+
+	authors := map[author]*authorStats{}
+	for key, n := range o {
+		a, ok := authors[key.author]
+		if !ok {
+			a = &authorStats{Name: key.name, Email: key.email,
+				Languages: map[string]int{}, Directories: map[string]int{}}
+			authors[key.author] = a
+		}
+		a.Code += n
+		a.Languages[key.language] += n
+		a.Directories[key.dir] += n
+		r.Total += n
+	}
+
+	r.Authors = make([]authorStats, 0, len(authors))
+	for _, a := range authors {
+		r.Authors = append(r.Authors, *a)
+	}
+	sort.Slice(r.Authors, func(i, j int) bool {
+		a, b := r.Authors[i], r.Authors[j]
+		if a.Code != b.Code {
+			return a.Code > b.Code
+		} else if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Email < b.Email
+	})
+	return r
+}
+
+// BlameFormats maps the name of a output format to the function that writes
+// the report in that format, in the blame mode.
+var blameFormats = map[string]func(io.Writer, blameReport) error{
+	"table": writeBlameTable,
+	"json":  writeBlameJSON,
+	"csv":   writeBlameCSV,
+}
+
+// MainBlame counts the code lines per author in the paths, at the revision
+// set by the -rev flag or HEAD, and writes the report, it's main for the
+// blame mode.
+func mainBlame(paths []string) {
+	write, ok := blameFormats[format]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Output format %s is not supported with -blame.\n", format)
+		exit(exitError)
+		return
+	}
+
+	blameRev := rev
+	if blameRev == "" {
+		blameRev = "HEAD"
+	}
+
+	var failed loc.Errors
+	o := ownership{}
+	for _, path := range paths {
+		pathOwnership, err := blame(blameRev, path)
+		failed.Add(err)
+		o.add(pathOwnership)
+	}
+
+	if err := write(os.Stdout, newBlameReport(blameRev, o)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %s.\n", err)
+		exit(exitError)
+		return
+	}
+
+	if len(failed) != 0 {
+		fmt.Fprintf(os.Stderr, "Failed to count %d file(s):\n", len(failed))
+		for _, err := range failed {
+			fmt.Fprintf(os.Stderr, "  %s\n", err)
+		}
+		exit(exitFailed)
+	}
+}
+
+// BlameFile holds the language of each line of a file that has code lines,
+// lines without code have an empty language.
+type blameFile struct {
+	path      string
+	languages []string
+}
+
+// Blame counts the code lines per author of the files in path at the
+// revision rev. The files are selected like countRev does and lexed like
+// countSource does, only files with code lines are blamed, using git blame
+// on a fixed number of workers, set by the -j flag.
+//
+// Errors are returned as loc.Errors, the ownership is still valid.
+func blame(rev, root string) (ownership, error) {
+	entries, err := lsTree(rev, root)
+	if err != nil {
+		return ownership{}, err
+	} else if len(entries) == 0 {
+		return ownership{}, fmt.Errorf("path %s doesn't exist in %s", root, rev)
+	}
+
+	var blobs []treeEntry
+	for _, entry := range entries {
+		if entry.mode != gitModeSymlink && !treeExcluded(root, entry.path) {
+			blobs = append(blobs, entry)
+		}
+	}
+
+	var errs loc.Errors
+	var files []blameFile
+	err = catBlobs(blobs, func(entry treeEntry, content []byte) {
+		f := blameFile{path: entry.path}
+		var hasCode bool
+		_, err := sourceLines(entry.path, bytes.NewReader(content), int64(len(content)),
+			func(name string, line []byte, code, comment bool) {
+				if !code {
+					name = ""
+				}
+				f.languages = append(f.languages, name)
+				hasCode = hasCode || code
+			})
+		if err != nil {
+			errs.Add(fmt.Errorf("%s:%s: %s", rev, entry.path, err))
+		} else if hasCode {
+			files = append(files, f)
+		}
+	})
+	errs.Add(err)
+
+	n := workers
+	if n < 1 {
+		n = 1
+	}
+
+	todo := make(chan blameFile, n)
+	go func() {
+		for _, f := range files {
+			todo <- f
+		}
+		close(todo)
+	}()
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex // Protects o and errs.
+		o  = ownership{}
+	)
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			for f := range todo {
+				authors, err := gitBlame(rev, f.path)
+
+				mu.Lock()
+				if err != nil {
+					errs.Add(fmt.Errorf("%s:%s: %s", rev, f.path, err))
+				} else {
+					o.add(fileOwnership(f, authors))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return o, errs.Err()
+}
+
+// FileOwnership attributes the code lines of the file to the authors of the
+// lines, as returned by gitBlame.
+func fileOwnership(f blameFile, authors []author) ownership {
+	o := ownership{}
+	dir := path.Dir(f.path)
+	for i, language := range f.languages {
+		if language == "" || i >= len(authors) {
+			continue
+		}
+		o[owner{authors[i], language, dir}]++
+	}
+	return o
+}
+
+// GitBlame returns the author of each line of the file at path in the
+// revision rev, using git blame --porcelain.
+func gitBlame(rev, path string) ([]author, error) {
+	out, err := git("blame", "--porcelain", rev, "--", path)
+	if err != nil {
+		return nil, err
+	}
+	return parseBlame(out)
+}
+
+// ParseBlame parses the output of git blame --porcelain, returning the author
+// of each line.
+func parseBlame(out []byte) ([]author, error) {
+	// The author of a commit is only given the first time the commit is used.
+	commits := map[string]*author{}
+
+	var (
+		lines   []author
+		current *author // Author of the commit of the current line.
+		final   int     // The current line number.
+		header  = true  // Whether the next line is a header line.
+	)
+	for _, line := range strings.Split(string(out), "\n") {
+		switch {
+		case header:
+			if line == "" {
+				continue
+			}
+
+			// Format: <commit> SP <original line> SP <final line> [SP <lines>].
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("unexpected git blame output %q", line)
+			}
+
+			n, err := strconv.Atoi(fields[2])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("unexpected git blame output %q", line)
+			}
+
+			final, header = n, false
+			if current = commits[fields[0]]; current == nil {
+				current = &author{}
+				commits[fields[0]] = current
+			}
+		case strings.HasPrefix(line, "\t"):
+			// The contents of the line ends the entry.
+			for len(lines) < final {
+				lines = append(lines, author{})
+			}
+			lines[final-1] = *current
+			header = true
+		case strings.HasPrefix(line, "author "):
+			current.name = strings.TrimPrefix(line, "author ")
+		case strings.HasPrefix(line, "author-mail "):
+			mail := strings.TrimPrefix(line, "author-mail ")
+			current.email = strings.TrimSuffix(strings.TrimPrefix(mail, "<"), ">")
+		}
+	}
+	return lines, nil
+}
+
+// WriteBlameTable writes the blame report as a human readable table, with a
+// row per author holding the code lines they own, their share of all code
+// lines and their languages, followed by the total.
+func writeBlameTable(w io.Writer, r blameReport) error {
+	const (
+		header    = "%-40s %8s %7s  %s\n"
+		row       = "%-40s %8d %6.1f%%  %s\n"
+		separator = "------------------------------------------------------------------------------\n"
+	)
+
+	ew := &errWriter{w: w}
+	ew.printf(header, "Author", "Code", "Share", "Languages")
+	ew.printf(separator)
+	for _, a := range r.Authors {
+		share := 100 * float64(a.Code) / float64(r.Total)
+		ew.printf(row, author{a.Name, a.Email}, a.Code, share, languageList(a.Languages))
+	}
+	ew.printf(separator)
+	ew.printf("%-40s %8d\n", "Total", r.Total)
+	return ew.err
+}
+
+// LanguageList returns the languages with their number of lines, most lines
+// first, e.g. "go 100, c 20".
+func languageList(languages map[string]int) string {
+	names := make([]string, 0, len(languages))
+	for name := range languages {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if languages[names[i]] != languages[names[j]] {
+			return languages[names[i]] > languages[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	for i, name := range names {
+		names[i] = name + " " + strconv.Itoa(languages[name])
+	}
+	return strings.Join(names, ", ")
+}
+
+// WriteBlameJSON writes the blame report as a single json object.
+func writeBlameJSON(w io.Writer, r blameReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(r)
+}
+
+// WriteBlameCSV writes the blame report as csv, with a header as the first
+// record. Each record holds the code lines of a single author in a single
+// language and directory.
+func writeBlameCSV(w io.Writer, r blameReport) error {
+	keys := make([]owner, 0, len(r.ownership))
+	for key := range r.ownership {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		switch {
+		case a.name != b.name:
+			return a.name < b.name
+		case a.email != b.email:
+			return a.email < b.email
+		case a.language != b.language:
+			return a.language < b.language
+		}
+		return a.dir < b.dir
+	})
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"version", "rev", "name", "email", "language", "directory",
+		"code"})
+	for _, key := range keys {
+		cw.Write([]string{strconv.Itoa(r.Version), r.Rev, key.name, key.email,
+			key.language, key.dir, strconv.Itoa(r.ownership[key])})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
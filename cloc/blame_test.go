@@ -0,0 +1,109 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBlame(t *testing.T) {
+	const out = "aaaa 1 1 2\n" +
+		"author Alice\n" +
+		"author-mail <alice@example.com>\n" +
+		"summary First\n" +
+		"filename main.go\n" +
+		"\tpackage main\n" +
+		"aaaa 2 2\n" +
+		"\t\n" +
+		"bbbb 1 3 1\n" +
+		"author Bob\n" +
+		"author-mail <bob@example.com>\n" +
+		"summary Second\n" +
+		"filename main.go\n" +
+		"\tfunc main() {}\n" +
+		"aaaa 3 4 1\n" +
+		"\t// Comment.\n"
+
+	alice := author{"Alice", "alice@example.com"}
+	bob := author{"Bob", "bob@example.com"}
+	expected := []author{alice, alice, bob, alice}
+
+	got, err := parseBlame([]byte(out))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	} else if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected parseBlame to return %v, got %v", expected, got)
+	}
+
+	if _, err := parseBlame([]byte("not porcelain\n")); err == nil {
+		t.Error("Expected an error for invalid output")
+	}
+}
+
+func TestFileOwnership(t *testing.T) {
+	alice := author{"Alice", "alice@example.com"}
+	bob := author{"Bob", "bob@example.com"}
+
+	f := blameFile{"lib/x.html", []string{"html", "", "javascript (embedded in html)", "html"}}
+	expected := ownership{
+		{alice, "html", "lib"}:                        1,
+		{bob, "javascript (embedded in html)", "lib"}: 1,
+		{bob, "html", "lib"}:                          1,
+	}
+
+	got := fileOwnership(f, []author{alice, alice, bob, bob})
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected fileOwnership to return %v, got %v", expected, got)
+	}
+}
+
+func TestBlame(t *testing.T) {
+	defer testRepo(t,
+		map[string]string{
+			"main.go":    "package main\n\n// Comment.\nfunc main() {}\n",
+			"lib/lib.go": "package lib\n",
+			"README":     "Not counted.\n",
+		},
+		map[string]string{
+			".mailmap":   "Tester <tester@example.com> <test@example.com>\n",
+			"lib/lib.go": "package lib\n\nvar x = 1\n",
+		},
+	)()
+
+	o, err := blame("HEAD", ".")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	tester := author{"Tester", "tester@example.com"}
+	expected := ownership{
+		{tester, "go", "."}:   2,
+		{tester, "go", "lib"}: 2,
+	}
+	if !reflect.DeepEqual(o, expected) {
+		t.Errorf("Expected blame to return %v, got %v", expected, o)
+	}
+
+	r := newBlameReport("HEAD", o)
+	if r.Total != 4 || len(r.Authors) != 1 || r.Authors[0].Directories["lib"] != 2 {
+		t.Errorf("Unexpected report %+v", r)
+	}
+
+	for name, write := range blameFormats {
+		var buf strings.Builder
+		if err := write(&buf, r); err != nil {
+			t.Errorf("Unexpected error writing %s: %s", name, err)
+		} else if !strings.Contains(buf.String(), "Tester") {
+			t.Errorf("Expected the %s output to hold the author, got:\n%s",
+				name, buf.String())
+		}
+	}
+
+	if _, err := blame("HEAD", "missing"); err == nil {
+		t.Error("Expected an error for a missing path")
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+// Budgets holds the maximum number of code lines per language, per directory
+// and in total, read from the file set by the -budgets flag, for example:
+//
+//	{
+//		"languages": {"go": 20000, "javascript": 0},
+//		"directories": {"cmd": 3000, "internal/legacy": 1000},
+//		"total": 50000
+//	}
+//
+// Languages and directories not in the file have no budget, a total of zero
+// means no budget. Directories are relative to the current directory.
+type budgets struct {
+	Languages   map[string]int `json:"languages"`
+	Directories map[string]int `json:"directories"`
+	Total       int            `json:"total"`
+}
+
+// ExceededBudget holds a single budget that is exceeded, either a budget of
+// the -budgets file or a baseline of the -baseline file.
+type exceededBudget struct {
+	name  string // E.g. "language go" or "directory cmd".
+	code  int    // Number of code lines.
+	limit int    // Maximum number of code lines.
+	// Description of the limit, e.g. "the budget of 1000".
+	desc string
+}
+
+func (e exceededBudget) String() string {
+	return fmt.Sprintf("%s: %d code lines, %d over %s", e.name, e.code,
+		e.code-e.limit, e.desc)
+}
+
+// LoadBudgets reads the budgets from the file at path.
+func loadBudgets(path string) (budgets, error) {
+	var b budgets
+	f, err := os.Open(path)
+	if err != nil {
+		return b, err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&b); err != nil {
+		return b, fmt.Errorf("%s: %s", path, err)
+	}
+
+	for name, max := range b.Languages {
+		if max < 0 {
+			return b, fmt.Errorf("%s: language %s: negative budget", path, name)
+		}
+	}
+	for dir, max := range b.Directories {
+		if max < 0 {
+			return b, fmt.Errorf("%s: directory %s: negative budget", path, dir)
+		}
+	}
+	if b.Total < 0 {
+		return b, fmt.Errorf("%s: negative total budget", path)
+	}
+	return b, nil
+}
+
+// CheckBudgets returns the budgets exceeded by the counts, languages first,
+// followed by directories and the total. The counts of the directories are
+// added up while counting, see dirCounter.
+func checkBudgets(b budgets, c loc.Counts, dirs *dirCounter) []exceededBudget {
+	var result []exceededBudget
+	for _, name := range sortedKeys(b.Languages) {
+		max := b.Languages[name]
+		if s := c[name]; s != nil && s.Code > max {
+			result = append(result, exceededBudget{"language " + name, s.Code, max,
+				fmt.Sprintf("the budget of %d", max)})
+		}
+	}
+
+	for _, dir := range sortedKeys(b.Directories) {
+		max := b.Directories[dir]
+		if code := dirs.counts(dir).Total().Code; code > max {
+			result = append(result, exceededBudget{"directory " + dir, code, max,
+				fmt.Sprintf("the budget of %d", max)})
+		}
+	}
+
+	if code := c.Total().Code; b.Total != 0 && code > b.Total {
+		result = append(result, exceededBudget{"total", code, b.Total,
+			fmt.Sprintf("the budget of %d", b.Total)})
+	}
+	return result
+}
+
+// DirCounter adds up the counts of the files counted in the directories with
+// a budget, so they don't have to be counted again. Only the files counted
+// are included, e.g. a directory outside the paths given by the user has no
+// code lines.
+type dirCounter struct {
+	mu   sync.Mutex
+	dirs map[string]string     // Absolute path -> directory in the budgets file.
+	all  map[string]loc.Counts // By directory in the budgets file.
+}
+
+// BudgetDirs holds the counts of the directories with a budget, set in main
+// if -budgets is used, nil otherwise. The counts of files are added by
+// countFile, countArchive and countEntries.
+var budgetDirs *dirCounter
+
+// NewDirCounter returns a dirCounter for the directories, relative to the
+// current directory.
+func newDirCounter(dirs []string) (*dirCounter, error) {
+	d := &dirCounter{dirs: map[string]string{}, all: map[string]loc.Counts{}}
+	for _, dir := range dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		d.dirs[abs] = dir
+		d.all[dir] = loc.Counts{}
+	}
+	return d, nil
+}
+
+// Add adds the counts of the file, or archive, at path to the directories
+// holding it.
+func (d *dirCounter) add(path string, c loc.Counts) {
+	d.update(path, func(dirCounts loc.Counts) { dirCounts.Add(c) })
+}
+
+// Sub subtracts the counts of the file at path from the directories holding
+// it, e.g. for a duplicate file, see fileSet.removeDuplicates.
+func (d *dirCounter) sub(path string, c loc.Counts) {
+	d.update(path, func(dirCounts loc.Counts) { subCounts(dirCounts, c) })
+}
+
+// Update calls fn with the counts of every directory holding path.
+func (d *dirCounter) update(path string, fn func(dirCounts loc.Counts)) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for dirpath, dir := range d.dirs {
+		if rel, err := filepath.Rel(dirpath, abs); err == nil && rel != ".." &&
+			!strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			fn(d.all[dir])
+		}
+	}
+}
+
+// Counts returns the counts of the directory dir, as named in the budgets
+// file.
+func (d *dirCounter) counts(dir string) loc.Counts {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.all[dir]
+}
+
+// LoadBaseline reads the baseline at path, the json output of a previous run.
+func loadBaseline(path string) (report, error) {
+	var r report
+	f, err := os.Open(path)
+	if err != nil {
+		return r, err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&r); err != nil {
+		return r, fmt.Errorf("%s: %s", path, err)
+	} else if r.Version != schemaVersion {
+		return r, fmt.Errorf("%s: unsupported schema version %d, expected %d",
+			path, r.Version, schemaVersion)
+	}
+	return r, nil
+}
+
+// CheckBaseline returns the languages, followed by the total, of which the
+// number of code lines grew by more than tolerance percent compared to the
+// baseline. Languages not in the baseline have a baseline of zero lines.
+func checkBaseline(baseline report, c loc.Counts, tolerance float64) []exceededBudget {
+	base := map[string]int{}
+	for _, s := range baseline.Languages {
+		base[s.Language] = s.Code
+	}
+
+	check := func(name string, code, baseCode int) *exceededBudget {
+		max := baseCode + int(float64(baseCode)*tolerance/100)
+		if code <= max {
+			return nil
+		}
+		return &exceededBudget{name, code, max, fmt.Sprintf(
+			"the maximum of %d (baseline %d + %g%%)", max, baseCode, tolerance)}
+	}
+
+	var result []exceededBudget
+	for _, s := range c.Sorted() {
+		if e := check("language "+s.Language, s.Code, base[s.Language]); e != nil {
+			result = append(result, *e)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].name < result[j].name })
+
+	if e := check("total", c.Total().Code, baseline.Total.Code); e != nil {
+		result = append(result, *e)
+	}
+	return result
+}
+
+// SortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,171 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+func TestLoadBudgets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	type test struct {
+		content  string
+		expected budgets
+		err      string
+	}
+
+	tests := []test{
+		{`{"languages": {"go": 10}, "directories": {"cmd": 5}, "total": 20}`,
+			budgets{map[string]int{"go": 10}, map[string]int{"cmd": 5}, 20}, ""},
+		{`{}`, budgets{}, ""},
+		{`{`, budgets{}, "unexpected EOF"},
+		{`{"languages": {"go": -1}}`, budgets{}, "language go: negative budget"},
+		{`{"directories": {"cmd": -1}}`, budgets{}, "directory cmd: negative budget"},
+		{`{"total": -1}`, budgets{}, "negative total budget"},
+	}
+
+	path := filepath.Join(dir, "budgets.json")
+	for _, test := range tests {
+		if err := ioutil.WriteFile(path, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := loadBudgets(path)
+		if test.err != "" {
+			if err == nil || !strings.HasSuffix(err.Error(), test.err) {
+				t.Errorf("Expected error %q for %s, got %v", test.err, test.content, err)
+			}
+		} else if err != nil {
+			t.Errorf("Unexpected error for %s: %s", test.content, err)
+		} else if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Expected loadBudgets to return %+v, got %+v", test.expected, got)
+		}
+	}
+}
+
+func TestCheckBudgets(t *testing.T) {
+	c := loc.Counts{
+		"go":         {Files: 2, Code: 100},
+		"javascript": {Files: 1, Code: 10},
+	}
+	dirs, err := newDirCounter([]string{"cmd/", "lib", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirs.add("cmd/main.go", loc.Counts{"go": {Files: 1, Code: 50}})
+	dirs.add(filepath.Join("cmd", "x", "x.go"), loc.Counts{"go": {Files: 1, Code: 10}})
+	dirs.add("lib/lib.go", loc.Counts{"go": {Files: 1, Code: 40}})
+	dirs.add("library/lib.go", loc.Counts{"go": {Files: 1, Code: 40}})
+
+	b := budgets{
+		Languages:   map[string]int{"go": 100, "javascript": 0, "c": 0},
+		Directories: map[string]int{"cmd/": 50, "lib": 40, "missing": 0},
+		Total:       100,
+	}
+	expected := []string{
+		"language javascript: 10 code lines, 10 over the budget of 0",
+		"directory cmd/: 60 code lines, 10 over the budget of 50",
+		"total: 110 code lines, 10 over the budget of 100",
+	}
+
+	got := checkBudgets(b, c, dirs)
+	if s := exceededStrings(got); !reflect.DeepEqual(s, expected) {
+		t.Errorf("Expected checkBudgets to return %q, got %q", expected, s)
+	}
+}
+
+func TestCheckBaseline(t *testing.T) {
+	var base report
+	base.add("", loc.Counts{
+		"go": {Files: 2, Code: 100},
+		"c":  {Files: 1, Code: 10},
+	})
+
+	type test struct {
+		counts    loc.Counts
+		tolerance float64
+		expected  []string
+	}
+
+	tests := []test{
+		{loc.Counts{"go": {Code: 100}, "c": {Code: 10}}, 0, nil},
+		{loc.Counts{"go": {Code: 90}}, 0, nil},
+		{loc.Counts{"go": {Code: 105}, "c": {Code: 5}}, 5, nil},
+		{loc.Counts{"go": {Code: 106}, "c": {Code: 10}}, 5, []string{
+			"language go: 106 code lines, 1 over the maximum of 105 (baseline 100 + 5%)",
+			"total: 116 code lines, 1 over the maximum of 115 (baseline 110 + 5%)",
+		}},
+		{loc.Counts{"go": {Code: 100}, "rust": {Code: 1}}, 50, []string{
+			"language rust: 1 code lines, 1 over the maximum of 0 (baseline 0 + 50%)",
+		}},
+	}
+
+	for _, test := range tests {
+		got := exceededStrings(checkBaseline(base, test.counts, test.tolerance))
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Expected checkBaseline(%v, %g) to return %q, got %q",
+				test.counts, test.tolerance, test.expected, got)
+		}
+	}
+}
+
+func exceededStrings(exceeded []exceededBudget) []string {
+	var s []string
+	for _, e := range exceeded {
+		s = append(s, e.String())
+	}
+	return s
+}
+
+func TestMainInvalidTolerance(t *testing.T) {
+	stderr, err := ioutil.TempFile("", "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(stderr.Name())
+
+	oldStderr, oldArgs, oldExit := os.Stderr, os.Args, exit
+	defer func() {
+		os.Stderr, os.Args, exit = oldStderr, oldArgs, oldExit
+		tolerance = 0
+	}()
+	os.Stderr = stderr
+
+	for _, value := range []string{"-1", "NaN", "+Inf"} {
+		exitCode := 0
+		exit = func(code int) { exitCode = code }
+		os.Args = []string{"", "-tolerance", value, "."}
+
+		main()
+
+		if exitCode != exitError {
+			t.Errorf("Expected exit code %d for tolerance %s, got %d", exitError,
+				value, exitCode)
+		}
+	}
+
+	output, err := ioutil.ReadFile(stderr.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Invalid tolerance -1, it must be zero or a positive percentage.\n" +
+		"Invalid tolerance NaN, it must be zero or a positive percentage.\n" +
+		"Invalid tolerance +Inf, it must be zero or a positive percentage.\n"
+	if string(output) != expected {
+		t.Errorf("Expected the errors to be '%s', got '%s'", expected, output)
+	}
+}
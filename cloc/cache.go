@@ -0,0 +1,276 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+// Files modified less than this long before they're counted are always
+// hashed in the next run, the file could be modified again without changing
+// the modification time.
+const racyWindow = 2 * time.Second
+
+// The version of the format of the cache, part of the fingerprint. It must be
+// incremented on every change to the cached data, e.g. cacheEntry, and on
+// every change to how files are counted or classified, which
+// TestCacheFormat checks.
+const cacheFormat = 4
+
+// Files not counted in a run are kept in the cache until they haven't been
+// used for maxCacheAge, keeping at most maxCacheEntries files, so that
+// counting different directories in turn doesn't invalidate the cache.
+const (
+	maxCacheAge     = 30 * 24 * time.Hour
+	maxCacheEntries = 100000
+)
+
+// Cache holds the counts of files of a previous run, so that only changed
+// files need to be counted again. The cache is stored per working directory
+// in the user's cache directory, see cachePath. It holds the files counted in
+// the last run and the recently used files of previous runs, see save.
+//
+// The cache is invalid if it was written by another version of cloc, in
+// another format or with other language definitions, see cacheFingerprint.
+type cache struct {
+	path string
+
+	mu    sync.Mutex
+	files map[string]cacheEntry // Files of the previous run.
+	used  map[string]cacheEntry // Files of the current run.
+}
+
+// CacheFile is the format of the cache file.
+type cacheFile struct {
+	Version     string                `json:"version"`
+	Fingerprint string                `json:"fingerprint"`
+	Files       map[string]cacheEntry `json:"files"` // By absolute path.
+}
+
+// CacheEntry holds the counts of a single file. A file is not counted again
+// if the size and modification time are the same, or if the contents have
+// the same hash.
+type cacheEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Hash    string    `json:"hash"` // Hex encoded SHA-256 of the contents.
+	// Normalised hash of the contents, see normalisedHasher, only set once the
+	// file is counted using -dedup.
+	Normalised string     `json:"normalised,omitempty"`
+	Counts     loc.Counts `json:"counts"`
+	Used       time.Time  `json:"used"` // Last run that counted the file.
+}
+
+// FileCache is the cache used by countFile, nil if the cache is not used.
+var fileCache *cache
+
+// CachePath returns the path of the cache file for the current directory.
+func cachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256([]byte(wd))
+	return filepath.Join(dir, "cloc", hex.EncodeToString(hash[:8])+".json"), nil
+}
+
+// CacheFingerprint returns the fingerprint of everything, other than the
+// contents of a file, that changes the counts of the file or the cached
+// data: the version of cloc, the cache format (which changes with the
+// counting code), the language definitions and the -generated policy.
+func cacheFingerprint() (string, error) {
+	definitions, err := json.Marshal(counter.Languages)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	io.WriteString(h, version+"\x00"+strconv.Itoa(cacheFormat)+"\x00"+
+		counter.Generated+"\x00")
+	h.Write(definitions)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// OpenCache reads the cache at path. If the file doesn't exist, or is
+// invalid, an empty cache is returned.
+func openCache(path string) (*cache, error) {
+	c := &cache{path: path, files: map[string]cacheEntry{}, used: map[string]cacheEntry{}}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	fingerprint, err := cacheFingerprint()
+	if err != nil {
+		return nil, err
+	}
+
+	var f cacheFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		// A corrupt cache is rebuild.
+		return c, nil
+	}
+
+	if f.Version == version && f.Fingerprint == fingerprint && f.Files != nil {
+		c.files = f.Files
+	}
+	return c, nil
+}
+
+// Save writes the files counted in this run to the cache file, replacing
+// the previous cache. Files of previous runs that were not counted in this
+// run are kept, unless they're not used for maxCacheAge. If there are more
+// than maxCacheEntries files the least recently used files are dropped.
+func (c *cache) save() error {
+	fingerprint, err := cacheFingerprint()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	files := make(map[string]cacheEntry, len(c.used))
+	for abs, entry := range c.used {
+		files[abs] = entry
+	}
+
+	var unused []string
+	for abs, entry := range c.files {
+		if _, ok := files[abs]; !ok && time.Since(entry.Used) < maxCacheAge {
+			unused = append(unused, abs)
+		}
+	}
+	sort.Slice(unused, func(i, j int) bool {
+		return c.files[unused[i]].Used.After(c.files[unused[j]].Used)
+	})
+	for _, abs := range unused {
+		if len(files) >= maxCacheEntries {
+			break
+		}
+		files[abs] = c.files[abs]
+	}
+
+	data, err := json.Marshal(cacheFile{version, fingerprint, files})
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so that concurrent runs never read a
+	// partially written cache.
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), "cache")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	} else if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// Count returns the counts of the open file at path, see countSource, using
+// the cached counts if the file didn't change. The counts are added to the
+// cache. If normalise is true it also returns the normalised hash of the
+// contents, see countSourceHash, which is cached as well.
+func (c *cache) count(path string, file *os.File, info os.FileInfo, normalise bool) (loc.Counts, string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	c.mu.Lock()
+	entry, ok := c.files[abs]
+	c.mu.Unlock()
+
+	if ok && entry.Size == info.Size() && !entry.ModTime.IsZero() &&
+		entry.ModTime.Equal(info.ModTime()) && (!normalise || entry.Normalised != "") {
+		c.use(abs, entry, info)
+		return copyCounts(entry.Counts), entry.Normalised, nil
+	}
+
+	// Both hashes are computed reading the file once.
+	h := sha256.New()
+	var w io.Writer = h
+	var n *normalisedHasher
+	if normalise {
+		n = newNormalisedHasher()
+		w = io.MultiWriter(h, n)
+	}
+	if _, err := io.Copy(w, file); err != nil {
+		return nil, "", err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	if !ok || entry.Hash != hash {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, "", err
+		}
+
+		fileCounts, err := countSource(path, file, info.Size())
+		if err != nil {
+			return nil, "", err
+		}
+		entry = cacheEntry{Hash: hash, Counts: fileCounts}
+	}
+	if normalise {
+		entry.Normalised = n.sum()
+	}
+
+	c.use(abs, entry, info)
+	return copyCounts(entry.Counts), entry.Normalised, nil
+}
+
+// CopyCounts returns a copy of c, so that the counts in the cache are not
+// changed by the caller, e.g. by fileSet.removeDuplicates.
+func copyCounts(c loc.Counts) loc.Counts {
+	cp := make(loc.Counts, len(c))
+	for name, s := range c {
+		st := *s
+		cp[name] = &st
+	}
+	return cp
+}
+
+// Use adds the entry to the files of this run, updating the size,
+// modification time and the time it's used.
+func (c *cache) use(abs string, entry cacheEntry, info os.FileInfo) {
+	entry.Size, entry.ModTime, entry.Used = info.Size(), info.ModTime(), time.Now()
+	if time.Since(entry.ModTime) < racyWindow {
+		entry.ModTime = time.Time{}
+	}
+
+	c.mu.Lock()
+	c.used[abs] = entry
+	c.mu.Unlock()
+}
@@ -0,0 +1,253 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "main.go")
+	cachePath := filepath.Join(dir, "cache", "cache.json")
+	old := time.Now().Add(-time.Hour)
+	write := func(content string, modTime time.Time) {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Counts the file using a new cache, saved after counting.
+	run := func() loc.Counts {
+		c, err := openCache(cachePath)
+		if err != nil {
+			t.Fatalf("Unexpected error opening the cache: %s", err)
+		}
+
+		defer func() { fileCache = nil }()
+		fileCache = c
+
+		got, err := countFile(path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if err := c.save(); err != nil {
+			t.Fatalf("Unexpected error saving the cache: %s", err)
+		}
+		return got
+	}
+
+	// Replaces the counts in the cache, to detect whether they are used.
+	fake := loc.Counts{"fake": &loc.Stats{Files: 1, Blank: 2, Comment: 3, Code: 4}}
+	tamper := func() {
+		c, err := openCache(cachePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for abs, entry := range c.files {
+			entry.Counts = fake
+			c.used[abs] = entry
+		}
+		if err := c.save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("package main\n", old)
+	expected := loc.Counts{"go": &loc.Stats{Files: 1, Code: 1}}
+	if got := run(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+
+	// Same size and modification time.
+	tamper()
+	if got := run(); !reflect.DeepEqual(got, fake) {
+		t.Errorf("Expected the cached counts, got %v", got)
+	}
+
+	// Modified, but the same contents.
+	write("package main\n", old.Add(time.Minute))
+	if got := run(); !reflect.DeepEqual(got, fake) {
+		t.Errorf("Expected the cached counts for the same contents, got %v", got)
+	}
+
+	// Changed contents.
+	write("package mein\n\n", old.Add(2*time.Minute))
+	expected = loc.Counts{"go": &loc.Stats{Files: 1, Blank: 1, Code: 1}}
+	if got := run(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	// Another -generated policy.
+	tamper()
+	defer func(old string) { counter.Generated = old }(counter.Generated)
+	counter.Generated = loc.GeneratedSkip
+	if got := run(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected the cache to be invalidated, got %v", got)
+	}
+
+	// Recently modified files are always hashed in the next run.
+	write("package main\n", time.Now())
+	run()
+	c, err := openCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range c.files {
+		if !entry.ModTime.IsZero() {
+			t.Errorf("Expected no modification time for a recently modified file, got %s",
+				entry.ModTime)
+		}
+	}
+
+	// A corrupt cache is ignored.
+	if err := ioutil.WriteFile(cachePath, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := openCache(cachePath); err != nil || len(c.files) != 0 {
+		t.Errorf("Expected an empty cache, got %v, %v", c, err)
+	}
+}
+
+func TestCacheKeepsUnused(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b := filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go")
+	for _, path := range []string{a, b} {
+		if err := ioutil.WriteFile(path, []byte("package main\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cachePath := filepath.Join(dir, "cache.json")
+
+	// Counts the files using a new cache, saved after counting.
+	run := func(paths ...string) *cache {
+		c, err := openCache(cachePath)
+		if err != nil {
+			t.Fatalf("Unexpected error opening the cache: %s", err)
+		}
+		for _, path := range paths {
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			info, err := f.Stat()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _, err = c.count(path, f, info, false)
+			f.Close()
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+		}
+		if err := c.save(); err != nil {
+			t.Fatalf("Unexpected error saving the cache: %s", err)
+		}
+		return c
+	}
+
+	run(a, b)
+	run(a)
+	c := run()
+	if len(c.files) != 2 {
+		t.Errorf("Expected both files to be kept in the cache, got %v", c.files)
+	}
+
+	// Files that are not used for a long time are dropped.
+	absB, err := filepath.Abs(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := c.files[absB]
+	entry.Used = time.Now().Add(-maxCacheAge - time.Hour)
+	c.files[absB] = entry
+	if err := c.save(); err != nil {
+		t.Fatal(err)
+	}
+	if c := run(); len(c.files) != 1 {
+		t.Errorf("Expected only a.go to be kept in the cache, got %v", c.files)
+	}
+}
+
+// The hash of the counts of the cacheFormat samples, see TestCacheFormat.
+var cacheFormatSamples = struct {
+	format int
+	hash   string
+}{4, "8086faa290c42847e7dd26156b0518aa2e5e565f2cc9ff406806ec7c9005bd38"}
+
+// TestCacheFormat fails if the counts of the files in _testdata, or of a few
+// files that are easily misclassified, change without incrementing
+// cacheFormat, which would mean old caches return stale counts. If the change
+// is intended increment cacheFormat and update cacheFormatSamples.
+func TestCacheFormat(t *testing.T) {
+	c := loc.NewCounter()
+	samples := map[string]string{
+		"gen.go":    "// Code generated by x. DO NOT EDIT.\n\npackage gen\n",
+		"string.go": "package x\n\nconst s = \"Code generated by x. DO NOT EDIT.\"\n",
+		"table.go":  "package x\n\nvar t = []string{" + strings.Repeat(`"abc", `, 50) + "}\n",
+		"data.sql":  "INSERT INTO t VALUES " + strings.Repeat("(1, 'abc'), ", 30) + ";\n",
+		"app.js":    strings.Repeat("var a=1;", 50) + "\n",
+		"image.c":   "\x7fELF\x02\x01\x01\x00",
+	}
+	err := filepath.Walk("_testdata", func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		content, err := ioutil.ReadFile(path)
+		samples[filepath.ToSlash(path)] = string(content)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	for path, content := range samples {
+		result, err := c.Count(path, strings.NewReader(content), int64(len(content)))
+		if err != nil {
+			t.Fatalf("Unexpected error counting %s: %s", path, err)
+		}
+
+		lines = append(lines, fmt.Sprintf("%s %q %q", path, result.Language, result.Kind))
+		for _, s := range result.Counts.Sorted() {
+			lines = append(lines, fmt.Sprintf("%s %q %+v", path, s.Language, s.Stats))
+		}
+	}
+	sort.Strings(lines)
+
+	hash := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	got := hex.EncodeToString(hash[:])
+	if cacheFormat != cacheFormatSamples.format || got != cacheFormatSamples.hash {
+		t.Errorf("The counts of the samples changed (hash %s), increment "+
+			"cacheFormat (%d, samples of %d) and update cacheFormatSamples",
+			got, cacheFormat, cacheFormatSamples.format)
+	}
+}
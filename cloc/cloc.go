@@ -5,263 +5,533 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"io/fs"
+	"math"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strings"
+	"runtime"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+// The version of cloc, it must be changed on every release as it also
+// invalidates the cache, see cacheFingerprint.
+const version = "0.0.1"
+
+// The counter used to count all files, its languages are extended using the
+// language definition files, see loadLanguageDefinitions.
+var counter = loc.NewCounter()
+
+// The output format, defaults to a table but can be changed using the -f and
+// -format flags.
+var format = "table"
+
+// Directories and glob patterns of files and directories to exclude, set
+// using the -exclude-dir and -exclude flags.
+var (
+	excludeDirs     stringList
+	excludePatterns stringList
+)
+
+// Don't read .gitignore and .ignore files, set using the -no-ignore flag.
+var noIgnore = false
+
+// The maximum depth of directories to count, set using the -max-depth flag.
+// A negative value means no limit.
+var maxDepth = -1
+
+// What to do with symbolic links, set using the -symlinks flag.
+var symlinks = symlinksFollow
+
+// The number of files counted in parallel, set using the -j flag.
+var workers = runtime.GOMAXPROCS(0)
+
+// Path to a file with language definitions, set using the -lang-def flag.
+var langDef = ""
+
+// Don't use the cache of the previous run, set using the -no-cache flag.
+var noCache = false
+
+// The git revision to count instead of the working tree, set using the -rev
+// flag.
+var rev = ""
+
+// Count the history of git commits, selected by the -history and -every
+// flags, see selectCommits. An empty history means counting a single
+// revision.
+var (
+	history = ""
+	every   = 1
+)
+
+// Compare two directories or git revisions, set using the -diff flag.
+var diffMode = false
+
+// Count the code lines per author using git blame, set using the -blame flag.
+var blameMode = false
+
+// Count Go packages instead of languages, set using the -go flag.
+var goMode = false
+
+// Report Go functions instead of languages, set using the -funcs flag, the
+// order, number of functions and thresholds are set using the -sort, -top,
+// -max-complexity and -max-lines flags. Zero means no limit.
+var (
+	funcsMode     = false
+	funcSort      = sortComplexity
+	top           = 0
+	maxComplexity = 0
+	maxLines      = 0
 )
 
+// Read the paths to count from a file, or stdin if "-", set using the
+// -files-from flag.
+var filesFrom = ""
+
+// Count the contents of stdin as the language, set using the -stdin-lang flag.
+var stdinLang = ""
+
+// Files with the maximum number of code lines and the counts of a previous
+// run to compare against, with a tolerance in percent, set using the
+// -budgets, -baseline and -tolerance flags, see budgets.go.
 var (
-	newLineBytes  = []byte("\n")
-	doubleNewLine = regexp.MustCompile(`\n\s*\n`)
+	budgetsFile = ""
+	baseline    = ""
+	tolerance   = 0.0
+)
+
+// Count identical files only once, set using the -dedup flag, see dedup.go.
+var dedup = false
+
+// Descriptions used for the flags.
+const (
+	formatDesc        = "Output format: table, json, csv or yaml, defaults to table"
+	excludeDirDesc    = "Comma separated directory names or paths to exclude"
+	excludeDesc       = "Comma separated glob patterns of paths to exclude"
+	noIgnoreDesc      = "Don't read .gitignore and .ignore files"
+	maxDepthDesc      = "Maximum depth of directories to count, defaults to no limit"
+	symlinksDesc      = "Symbolic link policy: follow (skipping loops) or skip"
+	workersDesc       = "Number of files counted in parallel, defaults to GOMAXPROCS"
+	langDefDesc       = "File with language definitions, see langdef.go"
+	noCacheDesc       = "Don't use the cache of the previous run in this directory"
+	revDesc           = "Git revision to count, e.g. a tag, without checking it out"
+	historyDesc       = "Count the history of commits: all, day, week or tag"
+	everyDesc         = "Only count every nth commit of the history"
+	diffModeDesc      = "Compare two directories or git revisions, given as arguments"
+	blameModeDesc     = "Count the code lines per author using git blame"
+	goModeDesc        = "Count Go packages, splitting test and non-test files"
+	funcsModeDesc     = "Report the complexity and length of Go functions"
+	funcSortDesc      = "Function sort order: complexity, lines or name"
+	topDesc           = "Only report the first n functions, defaults to all"
+	maxComplexityDesc = "Exit with status 4 if a function exceeds this complexity"
+	maxLinesDesc      = "Exit with status 4 if a function exceeds this number of lines"
+	generatedDesc     = "Generated, minified and binary file policy: separate, skip or count"
+	filesFromDesc     = "Read NUL or new line separated paths to count from a file, - for stdin"
+	stdinLangDesc     = "Count the contents of stdin as the language, given by name or alias"
+	budgetsDesc       = "Exit with status 4 if the code lines exceed the budgets in this file"
+	baselineDesc      = "Exit with status 4 if the code lines grew compared to this json output"
+	toleranceDesc     = "Percentage the code lines may grow compared to the -baseline"
+	dedupDesc         = "Count identical files only once and report the duplicates"
 )
 
-type language struct {
-	OneLine    []*regexp.Regexp // Regexp to detect single line comments.
-	MultiLine  []*regexp.Regexp // Regexp to detect multi line comments.
-	Extentions []string         // Known file extentions for the language.
+// The flags of cloc, see usage.
+var flags = flag.NewFlagSet("cloc", flag.ContinueOnError)
+
+func init() {
+	flags.StringVar(&format, "f", format, formatDesc)
+	flags.StringVar(&format, "format", format, formatDesc)
+	flags.Var(&excludeDirs, "exclude-dir", excludeDirDesc)
+	flags.Var(&excludePatterns, "exclude", excludeDesc)
+	flags.BoolVar(&noIgnore, "no-ignore", noIgnore, noIgnoreDesc)
+	flags.IntVar(&maxDepth, "max-depth", maxDepth, maxDepthDesc)
+	flags.StringVar(&symlinks, "symlinks", symlinks, symlinksDesc)
+	flags.IntVar(&workers, "j", workers, workersDesc)
+	flags.StringVar(&langDef, "lang-def", langDef, langDefDesc)
+	flags.BoolVar(&noCache, "no-cache", noCache, noCacheDesc)
+	flags.StringVar(&counter.Generated, "generated", counter.Generated, generatedDesc)
+	flags.StringVar(&rev, "rev", rev, revDesc)
+	flags.StringVar(&history, "history", history, historyDesc)
+	flags.IntVar(&every, "every", every, everyDesc)
+	flags.BoolVar(&diffMode, "diff", diffMode, diffModeDesc)
+	flags.BoolVar(&blameMode, "blame", blameMode, blameModeDesc)
+	flags.BoolVar(&goMode, "go", goMode, goModeDesc)
+	flags.BoolVar(&funcsMode, "funcs", funcsMode, funcsModeDesc)
+	flags.StringVar(&funcSort, "sort", funcSort, funcSortDesc)
+	flags.IntVar(&top, "top", top, topDesc)
+	flags.IntVar(&maxComplexity, "max-complexity", maxComplexity, maxComplexityDesc)
+	flags.IntVar(&maxLines, "max-lines", maxLines, maxLinesDesc)
+	flags.StringVar(&filesFrom, "files-from", filesFrom, filesFromDesc)
+	flags.StringVar(&stdinLang, "stdin-lang", stdinLang, stdinLangDesc)
+	flags.StringVar(&budgetsFile, "budgets", budgetsFile, budgetsDesc)
+	flags.StringVar(&baseline, "baseline", baseline, baselineDesc)
+	flags.Float64Var(&tolerance, "tolerance", tolerance, toleranceDesc)
+	flags.BoolVar(&dedup, "dedup", dedup, dedupDesc)
+	flags.Usage = usage
 }
 
+// Exit codes.
+const (
+	exitError    = 1 // Invalid options or writing the output failed.
+	exitUsage    = 2 // Unknown flags or invalid flag values.
+	exitFailed   = 3 // Not all files could be counted.
+	exitExceeded = 4 // A function or budget exceeds the thresholds, see mainFuncs and checkBudgets.
+)
+
+// Exit exits the program, for testing it can be overwritten.
+var exit = os.Exit
+
 func main() {
-	totalCount := 0
-	files := getFileOptions(os.Args)
+	paths, err := parseArgs(os.Args[1:])
+	if err == flag.ErrHelp {
+		exit(0)
+		return
+	} else if err != nil {
+		// The flag package already printed the error and the usage.
+		exit(exitUsage)
+		return
+	}
+
+	write, ok := formats[format]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown output format %s.\n", format)
+		exit(exitError)
+		return
+	}
+
+	if symlinks != symlinksFollow && symlinks != symlinksSkip {
+		fmt.Fprintf(os.Stderr, "Unknown symbolic link policy %s.\n", symlinks)
+		exit(exitError)
+		return
+	}
+
+	if g := counter.Generated; g != loc.GeneratedSeparate && g != loc.GeneratedSkip &&
+		g != loc.GeneratedCount {
+		fmt.Fprintf(os.Stderr, "Unknown generated file policy %s.\n", g)
+		exit(exitError)
+		return
+	}
+
+	if tolerance < 0 || math.IsNaN(tolerance) || math.IsInf(tolerance, 0) {
+		fmt.Fprintf(os.Stderr, "Invalid tolerance %g, it must be zero or a positive percentage.\n",
+			tolerance)
+		exit(exitError)
+		return
+	}
+
+	if err := loadLanguageDefinitions(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading language definitions: %s.\n", err)
+		exit(exitError)
+		return
+	}
+
+	if stdinLang != "" {
+		if len(paths) != 0 || filesFrom != "" || rev != "" || history != "" ||
+			diffMode || blameMode || goMode || funcsMode || budgetsFile != "" ||
+			baseline != "" || dedup {
+			fmt.Fprintf(os.Stderr, "Can't use -stdin-lang with paths, -files-from or other modes.\n")
+			exit(exitError)
+			return
+		}
+		mainStdin(write)
+		return
+	}
+
+	files, err := getFiles(paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading the paths to count: %s.\n", err)
+		exit(exitError)
+		return
+	}
 
-	for _, path := range files {
-		count, err := count(path)
-		if err != nil {
-			os.Stderr.WriteString(err.Error())
+	var r report
+	var failed loc.Errors
+	if (rev != "" || history != "") && (funcsMode || goMode || diffMode) {
+		fmt.Fprintf(os.Stderr, "Can't use -rev or -history with -go, -funcs or -diff.\n")
+		exit(exitError)
+		return
+	} else if (diffMode || blameMode) && (funcsMode || goMode || history != "") ||
+		(diffMode && blameMode) {
+		fmt.Fprintf(os.Stderr, "Can't use -diff or -blame with other modes.\n")
+		exit(exitError)
+		return
+	} else if (budgetsFile != "" || baseline != "") &&
+		(diffMode || blameMode || goMode || funcsMode || history != "") {
+		fmt.Fprintf(os.Stderr, "Can't use -budgets or -baseline with other modes.\n")
+		exit(exitError)
+		return
+	} else if dedup && (rev != "" || diffMode || blameMode || goMode || funcsMode ||
+		history != "") {
+		fmt.Fprintf(os.Stderr, "Can't use -dedup with -rev or other modes.\n")
+		exit(exitError)
+		return
+	} else if blameMode {
+		mainBlame(files)
+		return
+	} else if diffMode {
+		mainDiff(files)
+		return
+	} else if history != "" {
+		mainHistory(files)
+		return
+	} else if funcsMode {
+		mainFuncs(files)
+		return
+	} else if goMode {
+		mainGo(files)
+		return
+	}
+
+	var b budgets
+	if budgetsFile != "" {
+		if b, err = loadBudgets(budgetsFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading budgets: %s.\n", err)
+			exit(exitError)
+			return
+		}
+		if budgetDirs, err = newDirCounter(sortedKeys(b.Directories)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading budgets: %s.\n", err)
+			exit(exitError)
 			return
 		}
+	}
 
-		totalCount += count
+	var base report
+	if baseline != "" {
+		if base, err = loadBaseline(baseline); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading baseline: %s.\n", err)
+			exit(exitError)
+			return
+		}
+	}
 
-		// Current directory is clearer then ./.
-		if path == "./" {
-			path = "Current directory"
+	if !noCache && rev == "" {
+		if path, err := cachePath(); err != nil {
+			warn("not using the cache: %s", err)
+		} else if fileCache, err = openCache(path); err != nil {
+			warn("not using the cache: %s", err)
 		}
+	}
 
-		// Inform the user about the number of lines.
-		fmt.Printf("%s contains %d lines of code.\n", path, count)
+	if dedup {
+		duplicates = newFileSet()
 	}
 
-	// Print a total of number of lines if the user requested info on more then
-	// one file or directory.
-	if len(files) > 1 {
-		fmt.Printf("Total number of lines: %d.\n", totalCount)
+	names := make([]string, len(files))
+	pathCounts := make([]loc.Counts, len(files))
+	for i, path := range files {
+		// Even if some files failed we still report the counts of the others.
+		if rev != "" {
+			names[i] = rev + ":" + path
+			pathCounts[i], err = countRev(rev, path, nil)
+			failed.Add(err)
+			continue
+		}
+
+		names[i] = path
+		pathCounts[i], err = count(path)
+		failed.Add(err)
 	}
-}
 
-// GetFileOptions gets the files we need to count from the command line
-// arguments.
-func getFileOptions(args []string) []string {
-	var files []string
-	if len(args) > 1 {
-		skip := false
-
-		for _, arg := range args[1:] {
-			// If the last argument started with - or --, we'll skip this argument
-			// aswell.
-			if skip {
-				skip = false
-				continue
-			}
-
-			// If the argument starts with - or --, we need to skip it aswell as the
-			// next one.
-			length := len(arg)
-			if (length >= 1 && arg[:1] == "-") || (length >= 2 && arg[:2] == "--") {
-				skip = true
-				continue
-			}
-
-			arg = strings.TrimSpace(arg)
-			if arg == "" {
-				continue
-			}
-
-			// Otherwise we will count the number of lines in the file or directory.
-			files = append(files, filepath.Clean(arg))
+	if duplicates != nil {
+		r.Duplicates = duplicates.removeDuplicates(files, pathCounts)
+	}
+	for i, name := range names {
+		r.add(name, pathCounts[i])
+	}
+
+	var exceeded []exceededBudget
+	if budgetsFile != "" {
+		exceeded = checkBudgets(b, r.counts, budgetDirs)
+	}
+	if baseline != "" {
+		exceeded = append(exceeded, checkBaseline(base, r.counts, tolerance)...)
+	}
+
+	if fileCache != nil {
+		if err := fileCache.save(); err != nil {
+			warn("failed to save the cache: %s", err)
 		}
 	}
 
-	// Default to counting the code in the current diretory.
-	if len(files) == 0 {
-		files = []string{"./"}
+	if err := write(os.Stdout, r); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %s.\n", err)
+		exit(exitError)
+		return
+	}
+
+	if len(failed) != 0 {
+		fmt.Fprintf(os.Stderr, "Failed to count %d file(s):\n", len(failed))
+		for _, err := range failed {
+			fmt.Fprintf(os.Stderr, "  %s\n", err)
+		}
+	}
+
+	if len(exceeded) != 0 {
+		fmt.Fprintf(os.Stderr, "%d budget(s) exceeded:\n", len(exceeded))
+		for _, e := range exceeded {
+			fmt.Fprintf(os.Stderr, "  %s\n", e)
+		}
 	}
 
-	return files
+	// The highest exit status wins.
+	if len(exceeded) != 0 {
+		exit(exitExceeded)
+	} else if len(failed) != 0 {
+		exit(exitFailed)
+	}
 }
 
-// Count counts the number of lines of code in a file or all files in a
-// directory. Path can either be a file or a directory, in case of a directory
-// all subdirectories will be counted aswell.
+// Count counts the number of blank, comment and code lines in a file or all
+// files in a directory, per language. Path can either be a file or a
+// directory, in case of a directory all subdirectories will be counted aswell.
+// Zip and tar archives are counted like directories, see countArchive.
 //
-// If a file in not detected as a source file it will return 0 but without an
-// error.
+// If a file in not detected as a source file it will not be counted, but it
+// won't return an error either.
 //
 // Possible returned errors are mostly related to not being able to open or
-// read the given path.
-func count(path string) (int, error) {
+// read the given path. Even if an error is returned the counts hold all files
+// that could be counted, see countDir.
+func count(path string) (loc.Counts, error) {
 	path = filepath.Clean(path)
 
 	// Open the file.
 	file, err := os.Open(path)
 	if err != nil {
-		return 0, fmt.Errorf("Cannot open file %s.", path)
+		return loc.Counts{}, fmt.Errorf("Cannot open file %s.", path)
 	}
 
 	// Get the file information.
 	stat, err := file.Stat()
 	if err != nil {
-		return 0, fmt.Errorf("Cannot stat open file %s.", path)
+		return loc.Counts{}, fmt.Errorf("Cannot stat open file %s.", path)
 	}
 
 	// Close the file, we won't need it anymore.
 	err = file.Close()
 	if err != nil {
-		return 0, fmt.Errorf("Error closing file %s.", path)
+		return loc.Counts{}, fmt.Errorf("Error closing file %s.", path)
 	}
 
-	// Count the number of lines in the file or directory.
+	// Count the number of lines in the file, directory or archive.
 	if stat.Mode().IsDir() {
 		return countDir(path)
+	} else if archiveKind(path) != "" {
+		return countArchive(path)
 	} else {
 		return countFile(path)
 	}
 }
 
-// CountDir counts the number of lines of code in all files in a given
-// directory and it's subdirectories.
+// CountDir counts the number of blank, comment and code lines in all files in
+// a given directory and it's subdirectories, up to the depth set by the
+// -max-depth flag. Files and directories excluded by the flags or ignored by
+// the ignore rules (of the directory itself or parent directories) are not
+// counted.
 //
-// TODO(Thomas): support for ignoring directories.
-// TODO(Thomas): support for setting recursive level.
-func countDir(dirpath string) (int, error) {
-	dirpath = filepath.Clean(dirpath)
-
-	// Grap all the files and directories in the given directory.
-	files, err := ioutil.ReadDir(dirpath)
+// Symbolic links are followed or skipped based on the -symlinks flag, a
+// directory that is reached through a symbolic link loop is not counted but
+// reported as a warning.
+//
+// The files are counted using loc.Counter.CountFS, by a fixed number of
+// workers set by the -j flag, see countFile.
+//
+// Files and directories that can't be read don't stop the counting, instead
+// the counts of all other files are returned along with a loc.Errors holding
+// the errors of all failed files and directories.
+func countDir(dirpath string) (loc.Counts, error) {
+	w, err := newWalker(filepath.Clean(dirpath))
 	if err != nil {
-		return 0, err
-	}
-
-	// A counter for the counted number of lines and one for the number of files
-	// so we can make sure we wait for every one of them.
-	codeLineCounter := 0
-	fileCounter := len(files)
-
-	// A channel for the number of counted files and one for possible errors.
-	countChannel := make(chan int, fileCounter)
-	errorChannel := make(chan error, 1)
-
-	// For each file/directory in the directory.
-	for _, file := range files {
-		go func(path string) {
-			path = filepath.Join(dirpath, path)
-
-			// Count the number of lines of the directory or file.
-			count, err := count(path)
-			if err != nil {
-				errorChannel <- err
-				return
-			}
-
-			countChannel <- count
-		}(file.Name())
-	}
-
-	// Wait for a response from each file/directory and either respond with an
-	// error or add the count to the total number of lines.
-	for fileCounter > 0 {
-		select {
-		case count := <-countChannel:
-			codeLineCounter += count
-		case err := <-errorChannel:
-			return 0, err
-		}
+		return nil, err
+	} else if w == nil {
+		return loc.Counts{}, nil
+	}
 
-		// Need to wait for yet another less response.
-		fileCounter--
+	c := *counter
+	c.Workers = workers
+	if c.Workers < 1 {
+		c.Workers = 1
 	}
+	c.Skip = w.skip
+	c.CountFile = func(fsys fs.FS, name string) (loc.Result, error) {
+		path := w.fsys.path(name)
+		counts, err := countFileIn(dirpath, path)
+		return loc.Result{Path: path, Counts: counts}, err
+	}
+	dirCounts, err := c.CountFS(context.Background(), w.fsys, ".", nil)
 
-	// Cleanup.
-	close(countChannel)
-	close(errorChannel)
+	// The walker is done once CountFS returns.
+	errs := w.errs
+	errs.Add(err)
+	return dirCounts, errs.Err()
+}
 
-	return codeLineCounter, nil
+// CountFile counts the number of blank, comment and code lines in a single
+// given file, if the file is not a source file then we'll return empty counts,
+// but not an error. If the cache is used unchanged files are not counted
+// again, see cache.count.
+func countFile(path string) (loc.Counts, error) {
+	return countFileIn(path, path)
 }
 
-// CountFile counts the number of lines of code in a single given file, if the
-// file is not a source file then we'll return 0, but not an error.
-//
-// BUG(Thomas): Doesn't work with all encodings, BOM enconding generally
-// doesn't work.
-func countFile(path string) (int, error) {
+// CountFileIn counts the file at path like countFile, the file is found in
+// arg, the path given by the user, see fileSet.add.
+func countFileIn(arg, path string) (loc.Counts, error) {
 	path = filepath.Clean(path)
 
-	// Get the langauge from the file path.
-	lang := getLanguage(path)
-
-	// Not a source file so we'll return a count of 0.
-	if lang == languages["unkown"] {
-		return 0, nil
+	file, err := os.Open(path)
+	if err != nil {
+		// Without the contents we can only detect the language by the path, if
+		// that fails it's most likely not a source file.
+		if _, lang := counter.Languages.ByPath(path); lang == nil {
+			return loc.Counts{}, nil
+		}
+		return nil, err
 	}
+	defer file.Close()
 
-	fileBytes, err := ioutil.ReadFile(path)
+	info, err := file.Stat()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	// If the language supports single line comments remove them.
-	if lang.OneLine != nil {
-		// Replace all single line comments with a empty new line.
-		for _, oneLine := range lang.OneLine {
-			fileBytes = oneLine.ReplaceAll(fileBytes, newLineBytes)
-		}
+	var c loc.Counts
+	var hash string
+	switch {
+	case fileCache != nil:
+		c, hash, err = fileCache.count(path, file, info, duplicates != nil)
+	case duplicates != nil:
+		c, hash, err = countSourceHash(path, file, info.Size())
+	default:
+		c, err = countSource(path, file, info.Size())
 	}
-
-	// If the language supports multi line comments remove them.
-	if lang.MultiLine != nil {
-		// Replace all multi line comments with nothing.
-		for _, multiLine := range lang.MultiLine {
-			fileBytes = multiLine.ReplaceAll(fileBytes, []byte{})
-		}
+	if err != nil {
+		return nil, err
 	}
 
-	// Replace every double new line with a single new line, basicly dropping
-	// empty lines.
-	fileBytes = doubleNewLine.ReplaceAll(fileBytes, newLineBytes)
-
-	// Trim null bytes and space.
-	fileBytes = bytes.Trim(fileBytes, "\x00")
-	fileBytes = bytes.TrimSpace(fileBytes)
-
-	// Only count the number of lines if the file is not empty.
-	count := 0
-	if len(fileBytes) != 0 {
-		// The number of line endings plus 1 is the number of lines.
-		count = bytes.Count(fileBytes, newLineBytes) + 1
+	if budgetDirs != nil {
+		budgetDirs.add(path, c)
 	}
-
-	return count, nil
+	if duplicates != nil {
+		duplicates.add(arg, path, hash, c)
+	}
+	return c, nil
 }
 
-// GetLanguage detects the language based on the file extention.
-func getLanguage(path string) *language {
-	// Get the extention from the path.
-	ext := strings.TrimPrefix(filepath.Ext(path), ".")
-
-	// Check if it matches a known extention of one of the languages.
-	for _, lang := range languages {
-		for _, langExt := range lang.Extentions {
-			if langExt == ext {
-				return lang
-			}
-		}
-	}
+// CountSource counts the number of blank, comment and code lines in the
+// contents of the file at path, which must be read from the start, size is
+// the size of the file. See countFile.
+func countSource(path string, file loc.Source, size int64) (loc.Counts, error) {
+	result, err := counter.Count(path, file, size)
+	return result.Counts, err
+}
 
-	// Don't known the language, so we'll return the unkown language.
-	return languages["unkown"]
+// SourceLines detects the language of the file at path and calls fn for each
+// line of the file, see countSource. It returns the name of the language of
+// the file, or an empty string if the file isn't counted.
+func sourceLines(path string, file loc.Source, size int64, fn loc.LineFunc) (string, error) {
+	return counter.Lines(path, file, size, fn)
 }
@@ -9,267 +9,262 @@ package main
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
 )
 
-func TestGetLanguage(t *testing.T) {
+func init() {
+	// The tests calling main must not read or write the cache of the user.
+	noCache = true
+}
+
+func TestCountFile(t *testing.T) {
 	type test struct {
 		filepath string
-		expected *language
+		language string
+		expected loc.Stats
+		err      string
 	}
 
 	tests := []test{
-		{"file.as", languages["actionscript"]},
-		{"file.asa", languages["asp"]},
-		{"file.asp", languages["asp"]},
-		{"file.c", languages["c"]},
-		{"file.h", languages["c"]},
-		{"file.cs", languages["c#"]},
-		{"file.c++", languages["c++"]},
-		{"file.cpp", languages["c++"]},
-		{"file.cp", languages["c++"]},
-		{"file.cc", languages["c++"]},
-		{"file.hh", languages["c++"]},
-		{"file.clj", languages["clojure"]},
-		{"file.css", languages["css"]},
-		{"file.d", languages["d"]},
-		{"file.di", languages["d"]},
-		{"file.erl", languages["erlang"]},
-		{"file.hrl", languages["erlang"]},
-		{"file.go", languages["go"]},
-		{"file.dot", languages["dot"]},
-		{"file.DOT", languages["dot"]},
-		{"file.groovy", languages["groovy"]},
-		{"file.gvy", languages["groovy"]},
-		{"file.hs", languages["haskell"]},
-		{"file.html", languages["html"]},
-		{"file.htm", languages["html"]},
-		{"file.shtml", languages["html"]},
-		{"file.xhtml", languages["html"]},
-		{"file.phtml", languages["html"]},
-		{"file.tmpl", languages["html"]},
-		{"file.tpl", languages["html"]},
-		{"file.java", languages["java"]},
-		{"file.js", languages["javascript"]},
-		{"file.jsx", languages["javascript"]},
-		{"file.lisp", languages["lisp"]},
-		{"file.cl", languages["lisp"]},
-		{"file.l", languages["lisp"]},
-		{"file.lua", languages["lua"]},
-		{"file.m", languages["objective-c"]},
-		{"file.mm", languages["objective-c"]},
-		{"file.M", languages["objective-c"]},
-		{"file.ml", languages["ocaml"]},
-		{"file.mli", languages["ocaml"]},
-		{"file.mll", languages["ocaml"]},
-		{"file.pas", languages["pascal"]},
-		{"file.p", languages["pascal"]},
-		{"file.pl", languages["perl"]},
-		{"file.pm", languages["perl"]},
-		{"file.php", languages["php"]},
-		{"file.py", languages["python"]},
-		{"file.rpy", languages["python"]},
-		{"file.cpy", languages["python"]},
-		{"file.pyw", languages["python"]},
-		{"file.r", languages["r"]},
-		{"file.R", languages["r"]},
-		{"file.s", languages["r"]},
-		{"file.S", languages["r"]},
-		{"file.rb", languages["ruby"]},
-		{"file.rbx", languages["ruby"]},
-		{"file.rjs", languages["ruby"]},
-		{"file.rs", languages["rust"]},
-		{"file.scala", languages["scala"]},
-		{"file.sh", languages["shell"]},
-		{"file.bash", languages["shell"]},
-		{"file.zsh", languages["shell"]},
-		{"file.sql", languages["sql"]},
-		{"file.txt", languages["unkown"]},
-		{"somefile", languages["unkown"]},
-		{"go", languages["unkown"]},
+		{"file", "", loc.Stats{}, ""},
+		{"file.as", "actionscript", loc.Stats{Files: 1}, ""},
+		{"file.asp", "asp", loc.Stats{Files: 1}, ""},
+		{"file.c", "c", loc.Stats{Files: 1, Blank: 4, Comment: 7, Code: 13}, ""},
+		{"file.cs", "c#", loc.Stats{Files: 1}, ""},
+		{"file.go", "go", loc.Stats{Files: 1, Blank: 4, Comment: 9, Code: 9}, ""},
+		{"file.gvy", "groovy", loc.Stats{Files: 1}, ""},
+		{"file.h", "c", loc.Stats{Files: 1, Blank: 2, Comment: 2, Code: 6}, ""},
+		{"file.hs", "haskell", loc.Stats{Files: 1, Blank: 1, Comment: 3, Code: 3}, ""},
+		{"file.htm", "html", loc.Stats{Files: 1, Blank: 9, Comment: 2, Code: 10}, ""},
+		{"file.l", "lisp", loc.Stats{Files: 1, Comment: 3, Code: 2}, ""},
+		{"file.php", "php", loc.Stats{Files: 1, Code: 1}, ""},
+		{"README.md", "markdown", loc.Stats{Files: 1, Code: 1}, ""},
+		{"testdata1/file.cl", "lisp", loc.Stats{Files: 1}, ""},
+		{"testdata1/file.clj", "clojure", loc.Stats{Files: 1}, ""},
+		{"testdata1/file.cpp", "c++", loc.Stats{Files: 1, Blank: 3, Comment: 1, Code: 13}, ""},
+		{"testdata1/file.lisp", "lisp", loc.Stats{Files: 1}, ""},
+		{"testdata1/file.lua", "lua", loc.Stats{Files: 1}, ""},
+		{"testdata1/file.m", "objective-c", loc.Stats{Files: 1}, ""},
+		{"testdata1/file.p", "pascal", loc.Stats{Files: 1, Comment: 4, Code: 4}, ""},
+		{"testdata1/file.txt", "", loc.Stats{}, ""},
+		{"testdata1/README.md", "markdown", loc.Stats{Files: 1, Code: 1}, ""},
+		{"testdata2/file.css", "css", loc.Stats{Files: 1, Blank: 1, Comment: 1, Code: 3}, ""},
+		{"testdata2/file.d", "d", loc.Stats{Files: 1, Blank: 1, Comment: 3, Code: 4}, ""},
+		{"testdata2/file.dot", "dot", loc.Stats{Files: 1}, ""},
+		{"testdata2/file.erl", "erlang", loc.Stats{Files: 1}, ""},
+		{"testdata2/file.html", "html", loc.Stats{Files: 1, Comment: 6, Code: 10}, ""},
+		{"testdata2/file.java", "java", loc.Stats{Files: 1}, ""},
+		{"testdata2/file.js", "javascript", loc.Stats{Files: 1, Blank: 2, Comment: 2, Code: 5}, ""},
+		{"testdata2/README.md", "markdown", loc.Stats{Files: 1, Code: 1}, ""},
+		{"testdata2/testdata3/file.pl", "perl", loc.Stats{Files: 1, Blank: 1, Comment: 1, Code: 1}, ""},
+		{"testdata2/testdata3/file.py", "python", loc.Stats{Files: 1, Comment: 1, Code: 1}, ""},
+		{"testdata2/testdata3/file.r", "r", loc.Stats{Files: 1}, ""},
+		{"testdata2/testdata3/file.rb", "ruby", loc.Stats{Files: 1, Blank: 3, Comment: 7, Code: 5}, ""},
+		{"testdata2/testdata3/file.rs", "rust", loc.Stats{Files: 1, Blank: 3, Comment: 6, Code: 15}, ""},
+		{"testdata2/testdata3/file.scala", "scala", loc.Stats{Files: 1}, ""},
+		{"testdata2/testdata3/file.sh", "shell", loc.Stats{Files: 1, Comment: 1, Code: 1}, ""},
+		{"testdata2/testdata3/file.tmpl", "html", loc.Stats{Files: 1}, ""},
+		{"testdata2/testdata3/README.md", "markdown", loc.Stats{Files: 1, Blank: 1, Code: 2}, ""},
+		{"detect/Makefile", "make", loc.Stats{Files: 1, Blank: 1, Comment: 1, Code: 3}, ""},
+		{"detect/Dockerfile", "dockerfile", loc.Stats{Files: 1, Comment: 1, Code: 2}, ""},
+		{"detect/Rakefile", "ruby", loc.Stats{Files: 1, Code: 3}, ""},
+		{"detect/script", "python", loc.Stats{Files: 1, Comment: 2, Code: 1}, ""},
+		{"detect/run", "shell", loc.Stats{Files: 1, Comment: 1, Code: 1}, ""},
+		{"detect/notes.txt", "ruby", loc.Stats{Files: 1, Comment: 1, Code: 1}, ""},
+		{"detect/header.h", "c++", loc.Stats{Files: 1, Comment: 1, Code: 1}, ""},
+		{"detect/plain", "", loc.Stats{}, ""},
+		{"encoding/utf8.cs", "c#", loc.Stats{Files: 1, Blank: 1, Comment: 3, Code: 6}, ""},
+		{"encoding/utf8_bom.cs", "c#", loc.Stats{Files: 1, Blank: 1, Comment: 3, Code: 6}, ""},
+		{"encoding/utf16le_bom.cs", "c#", loc.Stats{Files: 1, Blank: 1, Comment: 3, Code: 6}, ""},
+		{"encoding/utf16be_bom.cs", "c#", loc.Stats{Files: 1, Blank: 1, Comment: 3, Code: 6}, ""},
+		{"encoding/utf16le.cs", "c#", loc.Stats{Files: 1, Blank: 1, Comment: 3, Code: 6}, ""},
+		{"encoding/utf16be.cs", "c#", loc.Stats{Files: 1, Blank: 1, Comment: 3, Code: 6}, ""},
+		{"encoding/latin1.c", "c", loc.Stats{Files: 1, Blank: 1, Comment: 3, Code: 6}, ""},
+		{"encoding/script", "python", loc.Stats{Files: 1, Comment: 2, Code: 1}, ""},
+		{"generated/kind_string.go", "go (generated)", loc.Stats{Files: 1, Blank: 5, Comment: 1, Code: 10}, ""},
+		{"generated/app.min.js", "javascript (minified)", loc.Stats{Files: 1, Code: 1}, ""},
+		{"generated/bundle.js", "javascript (minified)", loc.Stats{Files: 1, Comment: 1, Code: 1}, ""},
+		{"generated/image.c", "binary", loc.Stats{Files: 1}, ""},
+		{"not_found", "", loc.Stats{}, ""},
+		/*{"not_found.go", "", stats{}, "open _testdata" + string(os.PathSeparator) +
+		"not_found.go: The system cannot find the file specified."},*/
 	}
 
 	for _, test := range tests {
-		lang := getLanguage(test.filepath)
+		counts, err := countFile("_testdata/" + test.filepath)
 
-		if lang != test.expected {
-			t.Errorf("Expected getLanguage(%s) to return %v, got %v",
-				test.filepath, test.expected, lang)
+		if err != nil && err.Error() != test.err {
+			t.Errorf("Unexpected error %s, expected %s", err.Error(), test.err)
+			continue
 		}
-	}
-}
 
-func TestGetFileOptions(t *testing.T) {
-	type test struct {
-		args     []string
-		expected []string
-	}
-
-	tests := []test{
-		{[]string{"bin"}, []string{"./"}},
-		{[]string{"bin", "test"}, []string{"test"}},
-		{[]string{"bin", "test", "test.go"}, []string{"test", "test.go"}},
-		{[]string{"bin", "", "test", "test.go"}, []string{"test", "test.go"}},
-		{[]string{"bin", "-d", "test", "test.go"}, []string{"test.go"}},
-		{[]string{"bin", "--d", "test", "test.go"}, []string{"test.go"}},
-		{[]string{"bin", "-", "test", "test.go"}, []string{"test.go"}},
-		{[]string{"bin", "--", "test", "test.go"}, []string{"test.go"}},
-	}
-
-	for _, test := range tests {
-		result := getFileOptions(test.args)
+		if test.language == "" {
+			if len(counts) != 0 {
+				t.Errorf("Expected file %s not to be counted, but got %v",
+					test.filepath, counts)
+			}
+			continue
+		}
 
-		if len(result) != len(test.expected) {
-			t.Errorf("Expected getFileOptions(%v) to return %v, but got %v",
-				test.args, test.expected, result)
-			return
+		if len(counts) != 1 || counts[test.language] == nil {
+			t.Errorf("Expected file %s to be counted as %s, but got %v",
+				test.filepath, test.language, counts)
+			continue
 		}
 
-		for i, file := range result {
-			if test.expected[i] != file {
-				t.Errorf("Expected getFileOptions(%v) to return %v, but got %v",
-					test.args, test.expected, result)
-			}
+		if got := *counts[test.language]; got != test.expected {
+			t.Errorf("Expected %+v, but got %+v for file %s",
+				test.expected, got, test.filepath)
 		}
 	}
 }
 
-func TestCountFile(t *testing.T) {
+func TestCountDir(t *testing.T) {
 	type test struct {
-		filepath      string
-		expectedCount int
-		err           string
+		filepath string
+		expected loc.Stats
+		err      string
 	}
 
 	tests := []test{
-		{"file", 0, ""},
-		{"file.as", 0, ""},
-		{"file.asp", 0, ""},
-		{"file.c", 13, ""},
-		{"file.cs", 0, ""},
-		{"file.go", 9, ""},
-		{"file.gvy", 0, ""},
-		{"file.h", 6, ""},
-		{"file.hs", 0, ""},
-		{"file.htm", 10, ""},
-		{"file.l", 0, ""},
-		{"file.php", 1, ""},
-		{"README.md", 0, ""},
-		{"testdata1/file.cl", 0, ""},
-		{"testdata1/file.clj", 0, ""},
-		{"testdata1/file.cpp", 13, ""},
-		{"testdata1/file.lisp", 0, ""},
-		{"testdata1/file.lua", 0, ""},
-		{"testdata1/file.m", 0, ""},
-		{"testdata1/file.p", 0, ""},
-		{"testdata1/file.txt", 0, ""},
-		{"testdata1/README.md", 0, ""},
-		{"testdata2/file.css", 3, ""},
-		{"testdata2/file.d", 0, ""},
-		{"testdata2/file.dot", 0, ""},
-		{"testdata2/file.erl", 0, ""},
-		{"testdata2/file.html", 10, ""},
-		{"testdata2/file.java", 0, ""},
-		{"testdata2/file.js", 5, ""},
-		{"testdata2/README.md", 0, ""},
-		{"testdata2/testdata3/file.pl", 1, ""},
-		{"testdata2/testdata3/file.py", 1, ""},
-		{"testdata2/testdata3/file.r", 0, ""},
-		{"testdata2/testdata3/file.rb", 5, ""},
-		{"testdata2/testdata3/file.rs", 15, ""},
-		{"testdata2/testdata3/file.scala", 0, ""},
-		{"testdata2/testdata3/file.sh", 1, ""},
-		{"testdata2/testdata3/file.tmpl", 0, ""},
-		{"testdata2/testdata3/README.md", 0, ""},
-		{"not_found", 0, ""},
-		/*{"not_found.go", 0, "open _testdata" + string(os.PathSeparator) +
-		"not_found.go: The system cannot find the file specified."},*/
+		{"_testdata", loc.Stats{Files: 67, Blank: 70, Comment: 103, Code: 253}, ""},
+		/*{"not_found", stats{}, "open not_found: The system cannot find" +
+		"the file specified."},*/
 	}
 
 	for _, test := range tests {
-		count, err := countFile("_testdata/" + test.filepath)
+		counts, err := countDir(test.filepath)
 
 		if err != nil && err.Error() != test.err {
 			t.Errorf("Unexpected error %s, expected %s", err.Error(), test.err)
-			continue
+			return
 		}
 
-		if count != test.expectedCount {
-			t.Errorf("Expected %d lines of code, but got %d for file %s",
-				test.expectedCount, count, test.filepath)
+		if got := counts.Total(); got != test.expected {
+			t.Errorf("Expected %+v, but got %+v for directory %s",
+				test.expected, got, test.filepath)
 		}
 	}
 }
 
-func TestCountDir(t *testing.T) {
-	type test struct {
-		filepath      string
-		expectedCount int
-		err           string
+func TestCountDirErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloc")
+	if err != nil {
+		t.Fatal(err)
 	}
+	defer os.RemoveAll(dir)
 
-	tests := []test{
-		{"_testdata", 93, ""},
-		/*{"not_found", 0, "open not_found: The system cannot find" +
-		"the file specified."},*/
+	err = ioutil.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	for _, test := range tests {
-		count, err := countDir(test.filepath)
-
-		if err != nil && err.Error() != test.err {
-			t.Errorf("Unexpected error %s, expected %s", err.Error(), test.err)
-			return
+	// Two broken symbolic links, one looking like a Go file.
+	for _, name := range []string{"b.go", "c"} {
+		err = os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, name))
+		if err != nil {
+			t.Skipf("Can't create symbolic link: %s", err)
 		}
+	}
+
+	counts, err := countDir(dir)
+	errs, ok := err.(loc.Errors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, got %v", err)
+	}
 
-		if count != test.expectedCount {
-			t.Errorf("Expected %d lines of code, but got %d for directory %s",
-				test.expectedCount, count, test.filepath)
+	for i, name := range []string{"b.go", "c"} {
+		if !strings.Contains(errs[i].Error(), filepath.Join(dir, name)) {
+			t.Errorf("Expected error %d to be about %s, got %s", i, name, errs[i])
 		}
 	}
+
+	if expected := (loc.Stats{Files: 1, Code: 1}); counts.Total() != expected {
+		t.Errorf("Expected %+v, but got %+v", expected, counts.Total())
+	}
 }
 
 func TestCount(t *testing.T) {
 	type test struct {
-		filepath      string
-		expectedCount int
-		err           string
+		filepath string
+		expected loc.Stats
+		err      string
 	}
 
 	tests := []test{
-		{"_testdata/file.go", 9, "nil"},
-		{"_testdata", 93, "nil"},
-		/*{"notFound", 0, "Cannot open file notFound."},
-		{"notFound.go", 0, "Cannot open file notFound.go."},*/
+		{"_testdata/file.go", loc.Stats{Files: 1, Blank: 4, Comment: 9, Code: 9}, "nil"},
+		{"_testdata", loc.Stats{Files: 67, Blank: 70, Comment: 103, Code: 253}, "nil"},
+		/*{"notFound", stats{}, "Cannot open file notFound."},
+		{"notFound.go", stats{}, "Cannot open file notFound.go."},*/
 	}
 
 	for _, test := range tests {
-		count, err := count(test.filepath)
+		counts, err := count(test.filepath)
 
 		if err != nil && err.Error() != test.err {
 			t.Errorf("Expected error to be \"%v\", got \"%v\"", test.err, err)
 			return
 		}
 
-		if count != test.expectedCount {
-			t.Errorf("Expected %d lines of code, but got %d for file/directory %s",
-				test.expectedCount, count, test.filepath)
+		if got := counts.Total(); got != test.expected {
+			t.Errorf("Expected %+v, but got %+v for file/directory %s",
+				test.expected, got, test.filepath)
 		}
 	}
 }
 
+func TestPrintCounts(t *testing.T) {
+	c := loc.Counts{
+		"c":    {Files: 2, Blank: 7, Comment: 9, Code: 26},
+		"go":   {Files: 1, Blank: 4, Comment: 9, Code: 9},
+		"ruby": {Files: 1, Blank: 3, Comment: 7, Code: 26},
+	}
+
+	var buf bytes.Buffer
+	if err := printCounts(&buf, c); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := `Language            Files    Blank  Comment     Code
+------------------------------------------------------
+c                       2        7        9       26
+ruby                    1        3        7       26
+go                      1        4        9        9
+------------------------------------------------------
+Total                   4       14       25       61
+`
+
+	if got := buf.String(); got != expected {
+		t.Errorf("Expected the output to be '%s', got '%s'", expected, got)
+	}
+}
+
 func TestMain(t *testing.T) {
 	type test struct {
 		args     []string
 		expected string
 	}
 
-	file := "languages.go"
+	const separator = "------------------------------------------------------\n"
+	file := "_testdata/file.go"
 	tests := []test{
-		{[]string{"", file}, file + " contains 162 lines of code.\n"},
-		{[]string{"", file, "README.md"}, file + " contains 162 lines of code.\n" +
-			"README.md contains 0 lines of code.\nTotal number of lines: 162.\n"},
+		{[]string{"", file}, "Language            Files    Blank  Comment     Code\n" +
+			separator +
+			"go                      1        4        9        9\n" +
+			separator +
+			"Total                   1        4        9        9\n"},
+		{[]string{"", file, "_testdata/file.c"}, "Language            Files    Blank  Comment     Code\n" +
+			separator +
+			"c                       1        4        7       13\n" +
+			"go                      1        4        9        9\n" +
+			separator +
+			"Total                   2        8       16       22\n"},
 	}
 
 	oldStdout := os.Stdout
@@ -311,3 +306,133 @@ func TestMain(t *testing.T) {
 	os.Stdout = oldStdout
 	os.Args = oldArgs
 }
+
+func TestMainFailed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, "b.go"))
+	if err != nil {
+		t.Skipf("Can't create symbolic link: %s", err)
+	}
+
+	stdout, err := ioutil.TempFile(dir, "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr, err := ioutil.TempFile(dir, "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldStdout, oldStderr, oldArgs, oldExit := os.Stdout, os.Stderr, os.Args, exit
+	defer func() {
+		os.Stdout, os.Stderr, os.Args, exit = oldStdout, oldStderr, oldArgs, oldExit
+	}()
+
+	exitCode := 0
+	exit = func(code int) { exitCode = code }
+	os.Stdout, os.Stderr = stdout, stderr
+	os.Args = []string{"", filepath.Join(dir, "a.go"), dir, filepath.Join(dir, "c")}
+
+	main()
+
+	if exitCode != exitFailed {
+		t.Errorf("Expected exit code %d, got %d", exitFailed, exitCode)
+	}
+
+	output, err := ioutil.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The total must still be printed.
+	expected := "Total                   2        0        0        2\n"
+	if !strings.HasSuffix(string(output), expected) {
+		t.Errorf("Expected the output to end with '%s', got '%s'", expected, output)
+	}
+
+	output, err = ioutil.ReadFile(stderr.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "Failed to count 2 file(s):\n" +
+		"  stat " + filepath.Join(dir, "b.go") + ": no such file or directory\n" +
+		"  Cannot open file " + filepath.Join(dir, "c") + ".\n"
+	if string(output) != expected {
+		t.Errorf("Expected the errors to be '%s', got '%s'", expected, output)
+	}
+}
+
+func TestMainFailedAndExceeded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src")
+	if err := os.Mkdir(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(src, "a.go"), []byte("package a\n\nvar x = 1\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	budgetsPath := filepath.Join(dir, "budgets.json")
+	content := `{"directories": {"` + src + `": 1}, "total": 1}`
+	if err := ioutil.WriteFile(budgetsPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, err := ioutil.TempFile(dir, "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr, err := ioutil.TempFile(dir, "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldStdout, oldStderr, oldArgs, oldExit := os.Stdout, os.Stderr, os.Args, exit
+	defer func() {
+		os.Stdout, os.Stderr, os.Args, exit = oldStdout, oldStderr, oldArgs, oldExit
+		budgetsFile, budgetDirs = "", nil
+	}()
+
+	exitCode := 0
+	exit = func(code int) { exitCode = code }
+	os.Stdout, os.Stderr = stdout, stderr
+	os.Args = []string{"", "-budgets", budgetsPath, src, filepath.Join(dir, "c")}
+
+	main()
+
+	// Exceeding a budget is worse than failing to count a file.
+	if exitCode != exitExceeded {
+		t.Errorf("Expected exit code %d, got %d", exitExceeded, exitCode)
+	}
+
+	output, err := ioutil.ReadFile(stderr.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "Failed to count 1 file(s):\n" +
+		"  Cannot open file " + filepath.Join(dir, "c") + ".\n" +
+		"2 budget(s) exceeded:\n" +
+		"  directory " + src + ": 2 code lines, 1 over the budget of 1\n" +
+		"  total: 2 code lines, 1 over the budget of 1\n"
+	if string(output) != expected {
+		t.Errorf("Expected the errors to be '%s', got '%s'", expected, output)
+	}
+}
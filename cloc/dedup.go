@@ -0,0 +1,213 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+// FileSet holds the files counted, by the hash of their normalised contents,
+// to count identical files only once, see removeDuplicates.
+type fileSet struct {
+	mu    sync.Mutex
+	files map[fileKey][]countedFile
+}
+
+// FileKey identifies identical files. Files are only identical if they're
+// also counted as the same language, e.g. the same contents in a .js and a
+// .ts file are not.
+type fileKey struct {
+	hash     string // See normalisedHasher.
+	language string
+}
+
+// CountedFile is a single file added to a fileSet.
+type countedFile struct {
+	arg    string // Path given by the user the file is found in.
+	path   string
+	counts loc.Counts
+}
+
+// DuplicateGroup holds files with identical normalised contents, only the
+// first file, in order of the paths, is counted.
+type duplicateGroup struct {
+	Language string    `json:"language"`
+	Files    []string  `json:"files"`
+	Lines    loc.Stats `json:"lines"`    // Lines of a single file.
+	Excluded loc.Stats `json:"excluded"` // Lines of the files not counted.
+}
+
+// Duplicates holds the files counted by countFile, set using the -dedup
+// flag, nil if duplicates are counted.
+var duplicates *fileSet
+
+func newFileSet() *fileSet {
+	return &fileSet{files: map[fileKey][]countedFile{}}
+}
+
+// Add adds the file at path, found in arg (the path given by the user), with
+// the counts c and the normalised hash of its contents, see
+// normalisedHasher, to the set. Files that are not counted are not added.
+func (s *fileSet) add(arg, path, hash string, c loc.Counts) {
+	var language string
+	for name, st := range c {
+		if st.Files != 0 {
+			language = name
+		}
+	}
+	if language == "" {
+		return
+	}
+
+	s.mu.Lock()
+	key := fileKey{hash, language}
+	s.files[key] = append(s.files[key], countedFile{arg, path, c})
+	s.mu.Unlock()
+}
+
+// RemoveDuplicates removes the counts of duplicate files from the counts of
+// the paths given by the user they were found in, pathCounts holds the counts
+// of the paths in args, and from the directories with a budget, see
+// budgetDirs. Of every group of identical files only the first file is kept.
+// It returns all groups, in order of the first file.
+func (s *fileSet) removeDuplicates(args []string, pathCounts []loc.Counts) []duplicateGroup {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	argCounts := make(map[string]loc.Counts, len(args))
+	for i, arg := range args {
+		if _, ok := argCounts[arg]; !ok {
+			argCounts[arg] = pathCounts[i]
+		}
+	}
+
+	var groups []duplicateGroup
+	for key, files := range s.files {
+		if len(files) < 2 {
+			continue
+		}
+
+		sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+		g := duplicateGroup{Language: key.language, Lines: files[0].counts.Total()}
+		for i, f := range files {
+			g.Files = append(g.Files, f.path)
+			if i == 0 {
+				continue
+			}
+
+			g.Excluded.Add(f.counts.Total())
+			subCounts(argCounts[f.arg], f.counts)
+			if budgetDirs != nil {
+				budgetDirs.sub(f.path, f.counts)
+			}
+		}
+		groups = append(groups, g)
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Files[0] < groups[j].Files[0] })
+	return groups
+}
+
+// SubCounts subtracts the counts other from c, removing languages without
+// any lines or files left.
+func subCounts(c, other loc.Counts) {
+	for name, st := range other {
+		if c[name] == nil {
+			continue
+		}
+		c[name].Sub(*st)
+		if *c[name] == (loc.Stats{}) {
+			delete(c, name)
+		}
+	}
+}
+
+// CountSourceHash counts the file like countSource, while computing the
+// normalised hash of the contents read, see normalisedHasher. The hash is
+// empty if the file isn't counted.
+func countSourceHash(path string, file loc.Source, size int64) (loc.Counts, string, error) {
+	h := newNormalisedHasher()
+	r := io.TeeReader(file, h)
+	c, err := countSource(path, struct {
+		io.Reader
+		io.ReaderAt
+	}{r, file}, size)
+	if err != nil || len(c) == 0 {
+		return c, "", err
+	}
+
+	// Not all files are read to the end, e.g. binary files.
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		return nil, "", err
+	}
+	return c, h.sum(), nil
+}
+
+// NormalisedHasher computes the hex encoded SHA-256 hash of the contents
+// written to it, normalised so that files that only differ in line endings,
+// trailing white space, a byte order mark or trailing blank lines have the
+// same hash.
+type normalisedHasher struct {
+	h     hash.Hash
+	line  []byte // Current line, without the new line.
+	first bool   // Whether line is the first line.
+	blank int    // Blank lines are only written once they're followed by another line.
+}
+
+func newNormalisedHasher() *normalisedHasher {
+	return &normalisedHasher{h: sha256.New(), first: true}
+}
+
+func (n *normalisedHasher) Write(p []byte) (int, error) {
+	written := len(p)
+	for {
+		i := bytes.IndexByte(p, '\n')
+		if i == -1 {
+			n.line = append(n.line, p...)
+			return written, nil
+		}
+
+		n.line = append(n.line, p[:i]...)
+		n.writeLine()
+		p = p[i+1:]
+	}
+}
+
+func (n *normalisedHasher) writeLine() {
+	line := n.line
+	if n.first {
+		line = bytes.TrimPrefix(line, []byte("\xef\xbb\xbf"))
+		n.first = false
+	}
+
+	line = bytes.TrimRight(line, " \t\r")
+	if len(line) == 0 {
+		n.blank++
+	} else {
+		for ; n.blank > 0; n.blank-- {
+			n.h.Write([]byte("\n"))
+		}
+		n.h.Write(line)
+		n.h.Write([]byte("\n"))
+	}
+	n.line = n.line[:0]
+}
+
+// Sum returns the hash of all contents written, after which the hasher must
+// not be used anymore.
+func (n *normalisedHasher) sum() string {
+	// The last line may not end with a new line.
+	n.writeLine()
+	return hex.EncodeToString(n.h.Sum(nil))
+}
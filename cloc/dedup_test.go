@@ -0,0 +1,232 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+func TestNormalisedHash(t *testing.T) {
+	const src = "package x\n\nfunc F() {}\n"
+	tests := []struct {
+		src   string
+		equal bool
+	}{
+		{src, true},
+		{"package x\r\n\r\nfunc F() {}\r\n", true},
+		{"\xef\xbb\xbfpackage x\n\nfunc F() {}\n", true},
+		{"package x \n\t\nfunc F() {}\t\n", true},
+		{"package x\n\nfunc F() {}\n\n\n", true},
+		{"package x\n\nfunc F() {}", true},
+		{"package x\nfunc F() {}\n", false},
+		{"\npackage x\n\nfunc F() {}\n", false},
+		{"package x\n\n  func F() {}\n", false},
+		{"package y\n\nfunc F() {}\n", false},
+	}
+
+	expected := normalisedHash(src)
+	for _, test := range tests {
+		if got := normalisedHash(test.src); (got == expected) != test.equal {
+			t.Errorf("Expected the hash of %q to be equal (%t) to the hash of %q",
+				test.src, test.equal, src)
+		}
+
+		// The hash doesn't depend on how the contents are written.
+		h := newNormalisedHasher()
+		io.WriteString(h, test.src)
+		if got, want := h.sum(), normalisedHash(test.src); got != want {
+			t.Errorf("Expected the hash of %q written at once to be %s, got %s",
+				test.src, want, got)
+		}
+	}
+}
+
+// NormalisedHash returns the hash of s, written to a normalisedHasher byte by
+// byte.
+func normalisedHash(s string) string {
+	h := newNormalisedHasher()
+	io.Copy(h, iotest.OneByteReader(strings.NewReader(s)))
+	return h.sum()
+}
+
+func TestRemoveDuplicates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"a/main.go":        "package main\n\n// Main.\nfunc main() {}\n",
+		"a/vendor/x/x.go":  "package x\n",
+		"b/main.go":        "package main\r\n\r\n// Main.\r\nfunc main() {}\r\n",
+		"b/vendor/x/x.go":  "package x\n",
+		"b/vendor/x/x.txt": "package x\n",
+		"b/other.go":       "package other\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	duplicates = newFileSet()
+	budgetDirs, err = newDirCounter([]string{filepath.Join(dir, "b")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { duplicates, budgetDirs = nil, nil }()
+
+	// An archive with a copy of main.go.
+	archive := filepath.Join(dir, "c.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := zip.NewWriter(f)
+	w, err := z.Create("main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, files["a/main.go"])
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	args := []string{filepath.Join(dir, "a"), filepath.Join(dir, "b"), archive}
+	var pathCounts []loc.Counts
+	for _, path := range args {
+		c, err := count(path)
+		if err != nil {
+			t.Fatalf("Unexpected error counting %s: %s", path, err)
+		}
+		pathCounts = append(pathCounts, c)
+	}
+
+	groups := duplicates.removeDuplicates(args, pathCounts)
+	expectedGroups := []duplicateGroup{
+		{
+			Language: "go",
+			Files: []string{filepath.Join(dir, "a/main.go"), filepath.Join(dir, "b/main.go"),
+				archive + archiveSeparator + "main.go"},
+			Lines:    loc.Stats{Files: 1, Blank: 1, Comment: 1, Code: 2},
+			Excluded: loc.Stats{Files: 2, Blank: 2, Comment: 2, Code: 4},
+		},
+		{
+			Language: "go",
+			Files:    []string{filepath.Join(dir, "a/vendor/x/x.go"), filepath.Join(dir, "b/vendor/x/x.go")},
+			Lines:    loc.Stats{Files: 1, Code: 1},
+			Excluded: loc.Stats{Files: 1, Code: 1},
+		},
+	}
+	if !reflect.DeepEqual(groups, expectedGroups) {
+		t.Errorf("Expected the duplicate groups %+v, got %+v", expectedGroups, groups)
+	}
+
+	// Only the files in a are counted, and b/other.go.
+	expected := []loc.Counts{
+		{"go": &loc.Stats{Files: 2, Blank: 1, Comment: 1, Code: 3}},
+		{"go": &loc.Stats{Files: 1, Code: 1}},
+		{},
+	}
+	if !reflect.DeepEqual(pathCounts, expected) {
+		t.Errorf("Expected the counts %v, got %v", expected, pathCounts)
+	}
+
+	// The duplicates are not counted in directory budgets either.
+	if got := budgetDirs.counts(filepath.Join(dir, "b")); !reflect.DeepEqual(got, expected[1]) {
+		t.Errorf("Expected the counts of the budget directory %v, got %v", expected[1], got)
+	}
+}
+
+func TestRemoveDuplicatesCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Files modified recently are always hashed again, see racyWindow.
+	old := time.Now().Add(-time.Hour)
+	paths := []string{filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go")}
+	for _, path := range paths {
+		if err := ioutil.WriteFile(path, []byte("package x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cachePath := filepath.Join(dir, "cache", "cache.json")
+	defer func() { fileCache, duplicates = nil, nil }()
+
+	// Counts the files, using -dedup if dedup is true, and saves the cache.
+	run := func(dedup bool) []loc.Counts {
+		var err error
+		if fileCache, err = openCache(cachePath); err != nil {
+			t.Fatal(err)
+		}
+		duplicates = nil
+		if dedup {
+			duplicates = newFileSet()
+		}
+
+		pathCounts := make([]loc.Counts, len(paths))
+		for i, path := range paths {
+			if pathCounts[i], err = count(path); err != nil {
+				t.Fatalf("Unexpected error counting %s: %s", path, err)
+			}
+		}
+		if duplicates != nil {
+			duplicates.removeDuplicates(paths, pathCounts)
+		}
+
+		if err := fileCache.save(); err != nil {
+			t.Fatal(err)
+		}
+		return pathCounts
+	}
+
+	file := loc.Counts{"go": &loc.Stats{Files: 1, Code: 1}}
+	tests := []struct {
+		dedup    bool
+		expected []loc.Counts
+	}{
+		{true, []loc.Counts{file, {}}},
+		// Removing the duplicates must not change the cached counts.
+		{false, []loc.Counts{file, file}},
+		// The normalised hashes are cached.
+		{true, []loc.Counts{file, {}}},
+	}
+	for _, test := range tests {
+		if got := run(test.dedup); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Expected the counts %v using dedup %t, got %v",
+				test.expected, test.dedup, got)
+		}
+	}
+
+	for abs, entry := range fileCache.used {
+		if entry.Normalised == "" {
+			t.Errorf("Expected the normalised hash of %s to be cached", abs)
+		}
+	}
+}
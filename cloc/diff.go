@@ -0,0 +1,685 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+// The status of a file in a diff, also used as the categories of lines.
+const (
+	diffAdded     = "added"
+	diffRemoved   = "removed"
+	diffModified  = "modified"
+	diffUnchanged = "unchanged"
+)
+
+// The maximum number of added and removed lines in a single file for which
+// the lines are matched, see diffLines.
+const maxDiffEdits = 1000
+
+// DiffReport holds the differences between two versions of the same tree,
+// it's the data written in the diff mode, set using the -diff flag.
+type diffReport struct {
+	Version   int            `json:"version"`
+	From      string         `json:"from"`
+	To        string         `json:"to"`
+	Files     []fileDiff     `json:"files"` // Only files that changed.
+	Languages []languageDiff `json:"languages"`
+	Total     diffStats      `json:"total"`
+}
+
+// DiffStats holds the number of files and lines added, removed, modified and
+// unchanged. Modified and unchanged lines are counted as the kind of line
+// they are in the new version. Net holds the difference between the counts
+// of the new and the old version.
+type diffStats struct {
+	Added     loc.Stats `json:"added"`
+	Removed   loc.Stats `json:"removed"`
+	Modified  loc.Stats `json:"modified"`
+	Unchanged loc.Stats `json:"unchanged"`
+	Net       loc.Stats `json:"net"`
+}
+
+func (s *diffStats) add(other diffStats) {
+	s.Added.Add(other.Added)
+	s.Removed.Add(other.Removed)
+	s.Modified.Add(other.Modified)
+	s.Unchanged.Add(other.Unchanged)
+	s.Net.Add(other.Net)
+}
+
+// Category returns the stats of the category, one of the diff statuses.
+func (s *diffStats) category(status string) *loc.Stats {
+	switch status {
+	case diffAdded:
+		return &s.Added
+	case diffRemoved:
+		return &s.Removed
+	case diffModified:
+		return &s.Modified
+	default:
+		return &s.Unchanged
+	}
+}
+
+// Changed reports whether any file or line was added, removed or modified.
+func (s diffStats) changed() bool {
+	return s.Added != (loc.Stats{}) || s.Removed != (loc.Stats{}) || s.Modified != (loc.Stats{})
+}
+
+// FileDiff holds the differences of a single file, of all languages in the
+// file.
+type fileDiff struct {
+	Path     string `json:"path"`
+	Language string `json:"language"`
+	Status   string `json:"status"`
+	diffStats
+}
+
+// LanguageDiff holds the differences of a single language.
+type languageDiff struct {
+	Language string `json:"language"`
+	diffStats
+}
+
+// DiffCounts holds the differences per language.
+type diffCounts map[string]*diffStats
+
+func (c diffCounts) get(name string) *diffStats {
+	if c[name] == nil {
+		c[name] = &diffStats{}
+	}
+	return c[name]
+}
+
+func (c diffCounts) add(other diffCounts) {
+	for name, s := range other {
+		c.get(name).add(*s)
+	}
+}
+
+func (c diffCounts) total() diffStats {
+	var total diffStats
+	for _, s := range c {
+		total.add(*s)
+	}
+	return total
+}
+
+// Sorted returns the differences of all languages, sorted by name.
+func (c diffCounts) sorted() []languageDiff {
+	sorted := make([]languageDiff, 0, len(c))
+	for name, s := range c {
+		sorted = append(sorted, languageDiff{name, *s})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Language < sorted[j].Language
+	})
+	return sorted
+}
+
+// DiffFormats maps the name of a output format to the function that writes
+// the report in that format, in the diff mode.
+var diffFormats = map[string]func(io.Writer, diffReport) error{
+	"table": writeDiffTable,
+	"json":  writeDiffJSON,
+	"csv":   writeDiffCSV,
+}
+
+// MainDiff compares the two versions in args, directories or git revisions,
+// and writes the report, it's main for the diff mode.
+func mainDiff(args []string) {
+	write, ok := diffFormats[format]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Output format %s is not supported with -diff.\n", format)
+		exit(exitError)
+		return
+	}
+
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "-diff requires two directories or git revisions.\n")
+		exit(exitError)
+		return
+	}
+
+	var failed loc.Errors
+	snapshots := make([]snapshot, len(args))
+	for i, arg := range args {
+		s, err := loadSnapshot(arg)
+		if _, ok := err.(loc.Errors); err != nil && !ok {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %s.\n", arg, err)
+			exit(exitError)
+			return
+		}
+		failed.Add(err)
+		snapshots[i] = s
+	}
+
+	r, err := diffSnapshots(snapshots[0], snapshots[1])
+	failed.Add(err)
+	r.From, r.To = args[0], args[1]
+	for _, s := range snapshots {
+		if s.close != nil {
+			failed.Add(s.close())
+		}
+	}
+
+	if err := write(os.Stdout, r); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %s.\n", err)
+		exit(exitError)
+		return
+	}
+
+	if len(failed) != 0 {
+		fmt.Fprintf(os.Stderr, "Failed to count %d file(s):\n", len(failed))
+		for _, err := range failed {
+			fmt.Fprintf(os.Stderr, "  %s\n", err)
+		}
+		exit(exitFailed)
+	}
+}
+
+// Snapshot holds the files of a single version, keyed by the slash separated
+// path relative to the directory, or to the current directory for git
+// revisions. Only the hash of each file is kept, the contents are read one
+// file at a time when the versions are compared, see diffSnapshots.
+type snapshot struct {
+	hashes map[string]string // Git blob hash of the files, see gitBlobHash.
+	// Read returns the contents of the file at path.
+	read func(path string) ([]byte, error)
+	// Close releases the resources used by read, it may be nil.
+	close func() error
+}
+
+// LoadSnapshot lists all files in arg, which is a directory or otherwise a
+// git revision. Directories are walked like countDir does, only files in a
+// known language, see Languages.Detect, are included. For git revisions the
+// -exclude-dir, -exclude and -max-depth flags are respected like countRev
+// does, the language of a blob is detected once it's read.
+//
+// Files in a directory that can't be read are returned as loc.Errors, the
+// snapshot is still valid.
+func loadSnapshot(arg string) (snapshot, error) {
+	if info, err := os.Stat(arg); err == nil && info.IsDir() {
+		return dirSnapshot(arg)
+	}
+	return revSnapshot(arg)
+}
+
+func dirSnapshot(root string) (snapshot, error) {
+	root = filepath.Clean(root)
+
+	s := snapshot{
+		hashes: map[string]string{},
+		read: func(path string) ([]byte, error) {
+			return ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(path)))
+		},
+	}
+	var fileErrs loc.Errors
+	err := walkDir(root, func(path string) {
+		hash, err := sourceFileHash(path)
+		if err != nil {
+			fileErrs.Add(err)
+			return
+		} else if hash == "" {
+			return
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			fileErrs.Add(err)
+			return
+		}
+		s.hashes[filepath.ToSlash(rel)] = hash
+	})
+
+	var errs loc.Errors
+	errs.Add(err)
+	errs = append(errs, fileErrs...)
+	return s, errs.Err()
+}
+
+// SourceFileHash returns the git blob hash of the file at path, or an empty
+// string if the file is not in a known language.
+func sourceFileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	if _, lang := counter.Languages.Detect(path, f, info.Size()); lang == nil {
+		return "", nil
+	}
+	return gitBlobHash(f, info.Size())
+}
+
+func revSnapshot(rev string) (snapshot, error) {
+	entries, err := lsTree(rev, ".")
+	if err != nil {
+		return snapshot{}, err
+	}
+
+	s := snapshot{hashes: map[string]string{}}
+	for _, entry := range entries {
+		if entry.mode != gitModeSymlink && !treeExcluded(".", entry.path) {
+			s.hashes[filepath.ToSlash(entry.path)] = entry.object
+		}
+	}
+
+	blobs, err := newBlobReader()
+	if err != nil {
+		return snapshot{}, err
+	}
+	s.read = func(path string) ([]byte, error) {
+		return blobs.read(treeEntry{object: s.hashes[path], path: path})
+	}
+	s.close = blobs.close
+	return s, nil
+}
+
+// DiffSnapshots compares all files in the two versions, files are matched by
+// path, so a renamed file is counted as removed and added. A single pair of
+// files is read and compared at a time.
+//
+// Files that can't be counted are returned as loc.Errors, the report is still
+// valid.
+func diffSnapshots(from, to snapshot) (diffReport, error) {
+	paths := make([]string, 0, len(to.hashes))
+	for path := range to.hashes {
+		paths = append(paths, path)
+	}
+	for path := range from.hashes {
+		if _, ok := to.hashes[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	r := diffReport{Version: schemaVersion}
+	languages := diffCounts{}
+
+	var errs loc.Errors
+	for _, path := range paths {
+		var old, new sourceFile
+		var err error
+
+		// Lines are only compared to lines of the same file.
+		ids := map[string]int{}
+
+		oldHash, inFrom := from.hashes[path]
+		newHash, inTo := to.hashes[path]
+		if inFrom {
+			if old, err = readSnapshotFile(from, path, ids); err != nil {
+				errs.Add(fmt.Errorf("%s: %s", path, err))
+				continue
+			}
+		}
+
+		identical := inFrom && inTo && oldHash == newHash
+		if identical {
+			new = old
+		} else if inTo {
+			if new, err = readSnapshotFile(to, path, ids); err != nil {
+				errs.Add(fmt.Errorf("%s: %s", path, err))
+				continue
+			}
+		}
+
+		f, c := diffFile(path, old, new, identical)
+		if f.Language == "" {
+			// Not a source file in either version.
+			continue
+		}
+
+		languages.add(c)
+		if f.Status != diffUnchanged {
+			r.Files = append(r.Files, f)
+		}
+	}
+
+	r.Languages = languages.sorted()
+	r.Total = languages.total()
+	return r, errs.Err()
+}
+
+// ReadSnapshotFile reads the file at path in the snapshot and lexes it, see
+// readSourceFile.
+func readSnapshotFile(s snapshot, path string, ids map[string]int) (sourceFile, error) {
+	content, err := s.read(path)
+	if err != nil {
+		return sourceFile{}, err
+	}
+	return readSourceFile(path, content, ids)
+}
+
+// SourceFile holds the lines of a single version of a file.
+type sourceFile struct {
+	name  string // Language of the file, empty if it's not counted.
+	lines []diffLine
+}
+
+// DiffLine is a single line in a file, as lexed by sourceLines.
+type diffLine struct {
+	id         int    // The contents without surrounding white space, see readSourceFile.
+	name       string // Language of the line.
+	hasCode    bool
+	hasComment bool
+}
+
+// Stats returns the stats of only this line.
+func (l diffLine) stats() loc.Stats {
+	var s loc.Stats
+	s.AddLine(l.hasCode, l.hasComment)
+	return s
+}
+
+// ReadSourceFile lexes the contents of the file, see sourceLines. The
+// contents of each line, without leading and trailing white space, is
+// replaced by an id from ids, so that the lines of both versions of the file
+// can be compared cheaply.
+func readSourceFile(path string, content []byte, ids map[string]int) (sourceFile, error) {
+	var f sourceFile
+	name, err := sourceLines(path, bytes.NewReader(content), int64(len(content)),
+		func(name string, line []byte, hasCode, hasComment bool) {
+			key := string(bytes.TrimSpace(line))
+			id, ok := ids[key]
+			if !ok {
+				id = len(ids)
+				ids[key] = id
+			}
+			f.lines = append(f.lines, diffLine{id, name, hasCode, hasComment})
+		})
+	f.name = name
+	return f, err
+}
+
+// DiffFile compares the old and new version of a file, an empty language
+// means the file doesn't exist (or isn't counted) in that version. It
+// returns the differences of the file and per language.
+//
+// Within each block of changed lines the removed and added lines are paired
+// up and counted as modified, the remaining lines are counted as removed or
+// added.
+func diffFile(path string, old, new sourceFile, identical bool) (fileDiff, diffCounts) {
+	f := fileDiff{Path: path, Language: new.name, Status: diffModified}
+	switch {
+	case old.name == "":
+		f.Status = diffAdded
+	case new.name == "":
+		f.Language, f.Status = old.name, diffRemoved
+	case identical:
+		f.Status = diffUnchanged
+	}
+
+	c := diffCounts{}
+	if f.Language == "" {
+		return f, c
+	}
+
+	c.get(f.Language).category(f.Status).Files++
+	if old.name != "" {
+		c.get(old.name).Net.Files--
+	}
+	if new.name != "" {
+		c.get(new.name).Net.Files++
+	}
+
+	for _, l := range old.lines {
+		c.get(l.name).Net.Sub(l.stats())
+	}
+	for _, l := range new.lines {
+		c.get(l.name).Net.Add(l.stats())
+	}
+
+	count := func(status string, l diffLine) {
+		c.get(l.name).category(status).Add(l.stats())
+	}
+
+	// Walk the blocks of changed lines between the matched lines, the last
+	// block ends at the end of both files.
+	var i, j int
+	for _, m := range append(diffLines(old.lines, new.lines), lineMatch{len(old.lines), len(new.lines)}) {
+		removed, added := old.lines[i:m.old], new.lines[j:m.new]
+		for k := 0; k < len(removed) || k < len(added); k++ {
+			switch {
+			case k < len(removed) && k < len(added):
+				count(diffModified, added[k])
+			case k < len(removed):
+				count(diffRemoved, removed[k])
+			default:
+				count(diffAdded, added[k])
+			}
+		}
+
+		if m.new < len(new.lines) {
+			count(diffUnchanged, new.lines[m.new])
+		}
+		i, j = m.old+1, m.new+1
+	}
+
+	f.diffStats = c.total()
+	return f, c
+}
+
+// LineMatch is a pair of indices of the same line in the old and new version
+// of a file.
+type lineMatch struct {
+	old, new int
+}
+
+// DiffLines returns the lines that are unchanged between the old and new
+// version, in order. The common start and end are matched directly, the
+// lines in between are matched using Myers' diff algorithm. If more than
+// maxDiffEdits lines are added and removed in between, none of those lines
+// are matched.
+func diffLines(old, new []diffLine) []lineMatch {
+	var matches []lineMatch
+
+	prefix := 0
+	for prefix < len(old) && prefix < len(new) && old[prefix].id == new[prefix].id {
+		matches = append(matches, lineMatch{prefix, prefix})
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(new)-prefix &&
+		old[len(old)-1-suffix].id == new[len(new)-1-suffix].id {
+		suffix++
+	}
+
+	a := make([]int, len(old)-prefix-suffix)
+	for i := range a {
+		a[i] = old[prefix+i].id
+	}
+	b := make([]int, len(new)-prefix-suffix)
+	for i := range b {
+		b[i] = new[prefix+i].id
+	}
+
+	for _, m := range myers(a, b, maxDiffEdits) {
+		matches = append(matches, lineMatch{prefix + m.old, prefix + m.new})
+	}
+
+	for i := suffix; i > 0; i-- {
+		matches = append(matches, lineMatch{len(old) - i, len(new) - i})
+	}
+	return matches
+}
+
+// Myers returns the matching elements of a longest common subsequence of a
+// and b, in order, using the algorithm described in "An O(ND) Difference
+// Algorithm and Its Variations" by Eugene W. Myers. If more than max edits
+// are needed it returns nil.
+func myers(a, b []int, max int) []lineMatch {
+	n, m := len(a), len(b)
+	if n+m < max {
+		max = n + m
+	}
+
+	// V holds the furthest x on each diagonal k (x - y), offset by max+1. The
+	// trace holds the part of v used in each step, for backtracking.
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // Insertion.
+			} else {
+				x = v[offset+k-1] + 1 // Deletion.
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(trace, n, m)
+			}
+		}
+	}
+	return nil
+}
+
+// Backtrack follows the furthest reaching paths found by myers back from the
+// end, collecting the matching elements along the way.
+func backtrack(trace [][]int, x, y int) []lineMatch {
+	var matches []lineMatch
+	for d := len(trace) - 1; d >= 0; d-- {
+		// The v of the previous step, offset by d+1.
+		v := trace[d]
+		k := x - y
+
+		prevK := k - 1
+		if k == -d || (k != d && v[k-1+d+1] < v[k+1+d+1]) {
+			prevK = k + 1
+		}
+		prevX := v[prevK+d+1]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			matches = append(matches, lineMatch{x, y})
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+		matches[i], matches[j] = matches[j], matches[i]
+	}
+	return matches
+}
+
+// WriteDiffTable writes the diff report as a human readable table. The
+// changed files are listed first, with their added, removed, modified and net
+// code lines, followed by all categories of each changed language and the
+// total.
+func writeDiffTable(w io.Writer, r diffReport) error {
+	const (
+		fileHeader = "%-10s %8s %8s %8s %8s  %s\n"
+		fileRow    = "%-10s %8d %8d %8d %+8d  %s\n"
+		header     = "%-24s %8s %8s %8s %8s\n"
+		title      = "%s\n"
+		row        = "  %-22s %8d %8d %8d %8d\n"
+		netRow     = "  %-22s %+8d %+8d %+8d %+8d\n"
+		separator  = "--------------------------------------------------------------\n"
+	)
+
+	ew := &errWriter{w: w}
+	if len(r.Files) != 0 {
+		ew.printf(fileHeader, "Status", "Added", "Removed", "Modified", "Net", "File (code lines)")
+		ew.printf(separator)
+		for _, f := range r.Files {
+			ew.printf(fileRow, f.Status, f.Added.Code, f.Removed.Code,
+				f.Modified.Code, f.Net.Code, f.Path)
+		}
+		ew.printf("\n")
+	}
+
+	printStats := func(name string, s diffStats) {
+		ew.printf(title, name)
+		for _, status := range []string{diffUnchanged, diffModified, diffAdded, diffRemoved} {
+			c := s.category(status)
+			ew.printf(row, status, c.Files, c.Blank, c.Comment, c.Code)
+		}
+		ew.printf(netRow, "net", s.Net.Files, s.Net.Blank, s.Net.Comment, s.Net.Code)
+	}
+
+	ew.printf(header, "Language", "Files", "Blank", "Comment", "Code")
+	ew.printf(separator)
+	for _, l := range r.Languages {
+		if l.changed() {
+			printStats(l.Language, l.diffStats)
+		}
+	}
+	ew.printf(separator)
+	printStats("Total", r.Total)
+	return ew.err
+}
+
+// WriteDiffJSON writes the diff report as a single json object.
+func writeDiffJSON(w io.Writer, r diffReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(r)
+}
+
+// WriteDiffCSV writes the diff report as csv, with a header as the first
+// record. Each record holds a single category (added, removed, modified,
+// unchanged or net) of a single changed file or language. Records with an
+// empty path hold the stats of a language, a record with an empty path and
+// language holds the total.
+func writeDiffCSV(w io.Writer, r diffReport) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"version", "path", "language", "category", "files",
+		"blank", "comment", "code"})
+
+	record := func(path, language string, s diffStats) {
+		for _, category := range []string{diffAdded, diffRemoved, diffModified, diffUnchanged, "net"} {
+			c := s.Net
+			if category != "net" {
+				c = *s.category(category)
+			}
+			cw.Write([]string{strconv.Itoa(r.Version), path, language, category,
+				strconv.Itoa(c.Files), strconv.Itoa(c.Blank), strconv.Itoa(c.Comment),
+				strconv.Itoa(c.Code)})
+		}
+	}
+
+	for _, f := range r.Files {
+		record(f.Path, f.Language, f.diffStats)
+	}
+	for _, l := range r.Languages {
+		record("", l.Language, l.diffStats)
+	}
+	record("", "", r.Total)
+
+	cw.Flush()
+	return cw.Error()
+}
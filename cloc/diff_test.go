@@ -0,0 +1,244 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+func TestDiffLines(t *testing.T) {
+	lines := func(s string) []diffLine {
+		var l []diffLine
+		for _, c := range s {
+			l = append(l, diffLine{id: int(c)})
+		}
+		return l
+	}
+
+	type test struct {
+		old, new string
+		expected []lineMatch
+	}
+
+	tests := []test{
+		{"", "", nil},
+		{"abc", "", nil},
+		{"", "abc", nil},
+		{"abc", "abc", []lineMatch{{0, 0}, {1, 1}, {2, 2}}},
+		{"abc", "axc", []lineMatch{{0, 0}, {2, 2}}},
+		{"abc", "abxc", []lineMatch{{0, 0}, {1, 1}, {2, 3}}},
+		{"abcd", "acd", []lineMatch{{0, 0}, {2, 1}, {3, 2}}},
+		{"abcabba", "cbabac", []lineMatch{{2, 0}, {3, 2}, {4, 3}, {6, 4}}},
+		{"xaybz", "aqbr", []lineMatch{{1, 0}, {3, 2}}},
+	}
+
+	for _, test := range tests {
+		got := diffLines(lines(test.old), lines(test.new))
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Expected diffLines(%q, %q) to return %v, got %v",
+				test.old, test.new, test.expected, got)
+		}
+	}
+}
+
+func TestMyersMaxEdits(t *testing.T) {
+	if got := myers([]int{1, 2, 3}, []int{4, 2, 5}, 3); got != nil {
+		t.Errorf("Expected myers to give up after 3 edits, got %v", got)
+	}
+
+	expected := []lineMatch{{1, 1}}
+	if got := myers([]int{1, 2, 3}, []int{4, 2, 5}, 4); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected myers to return %v, got %v", expected, got)
+	}
+}
+
+// MemSnapshot returns a snapshot of the files, by path.
+func memSnapshot(t *testing.T, files map[string]string) snapshot {
+	s := snapshot{
+		hashes: map[string]string{},
+		read: func(path string) ([]byte, error) {
+			return []byte(files[path]), nil
+		},
+	}
+	for path, content := range files {
+		hash, err := gitBlobHash(strings.NewReader(content), int64(len(content)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.hashes[path] = hash
+	}
+	return s
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	from := memSnapshot(t, map[string]string{
+		"main.go":    "package main\n\n// Old comment.\nfunc main() {\n\tprintln(1)\n\tprintln(2)\n}\n",
+		"removed.go": "package main\n\nvar x = 1\n",
+		"same.c":     "int x;\n",
+		"README":     "Not counted.\n",
+	})
+	to := memSnapshot(t, map[string]string{
+		// Modified: a comment removed, a line changed and a line added.
+		"main.go":  "package main\n\nfunc main() {\n\tprintln(10)\n\tprintln(2)\n\tprintln(3)\n}\n",
+		"added.js": "// New.\nvar a = 1;\n",
+		"same.c":   "int x;\n",
+		"README":   "Still not counted.\n",
+	})
+
+	r, err := diffSnapshots(from, to)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expectedFiles := []fileDiff{
+		{Path: "added.js", Language: "javascript", Status: diffAdded, diffStats: diffStats{
+			Added: loc.Stats{Files: 1, Comment: 1, Code: 1},
+			Net:   loc.Stats{Files: 1, Comment: 1, Code: 1},
+		}},
+		{Path: "main.go", Language: "go", Status: diffModified, diffStats: diffStats{
+			Added:     loc.Stats{Code: 1},
+			Removed:   loc.Stats{Comment: 1},
+			Modified:  loc.Stats{Files: 1, Code: 1},
+			Unchanged: loc.Stats{Blank: 1, Code: 4},
+			Net:       loc.Stats{Comment: -1, Code: 1},
+		}},
+		{Path: "removed.go", Language: "go", Status: diffRemoved, diffStats: diffStats{
+			Removed: loc.Stats{Files: 1, Blank: 1, Code: 2},
+			Net:     loc.Stats{Files: -1, Blank: -1, Code: -2},
+		}},
+	}
+	if !reflect.DeepEqual(r.Files, expectedFiles) {
+		t.Errorf("Expected files %+v, got %+v", expectedFiles, r.Files)
+	}
+
+	expectedLanguages := []languageDiff{
+		{"c", diffStats{Unchanged: loc.Stats{Files: 1, Code: 1}}},
+		{"go", diffStats{
+			Added:     loc.Stats{Code: 1},
+			Removed:   loc.Stats{Files: 1, Blank: 1, Comment: 1, Code: 2},
+			Modified:  loc.Stats{Files: 1, Code: 1},
+			Unchanged: loc.Stats{Blank: 1, Code: 4},
+			Net:       loc.Stats{Files: -1, Blank: -1, Comment: -1, Code: -1},
+		}},
+		{"javascript", diffStats{
+			Added: loc.Stats{Files: 1, Comment: 1, Code: 1},
+			Net:   loc.Stats{Files: 1, Comment: 1, Code: 1},
+		}},
+	}
+	if !reflect.DeepEqual(r.Languages, expectedLanguages) {
+		t.Errorf("Expected languages %+v, got %+v", expectedLanguages, r.Languages)
+	}
+
+	expectedTotal := diffStats{
+		Added:     loc.Stats{Files: 1, Comment: 1, Code: 2},
+		Removed:   loc.Stats{Files: 1, Blank: 1, Comment: 1, Code: 2},
+		Modified:  loc.Stats{Files: 1, Code: 1},
+		Unchanged: loc.Stats{Files: 1, Blank: 1, Code: 5},
+		Net:       loc.Stats{Blank: -1},
+	}
+	if r.Total != expectedTotal {
+		t.Errorf("Expected total %+v, got %+v", expectedTotal, r.Total)
+	}
+}
+
+func TestDiffRevAndDir(t *testing.T) {
+	defer testRepo(t,
+		map[string]string{
+			"main.go":     "package main\n\nfunc main() {}\n",
+			"lib/lib.go":  "package lib\n",
+			"lib/old.go":  "package lib\n\nvar old = 1\n",
+			"web/app.js":  "var a = 1;\n",
+			"vendor/v.go": "package v\n",
+			"notes.txt":   "Not counted.\n",
+		},
+	)()
+
+	// The working directory, after the commit.
+	if err := os.Remove(filepath.Join("lib", "old.go")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile("main.go", []byte("package main\n\n// Main.\nfunc main() {\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(old stringList) { excludeDirs = old }(excludeDirs)
+	excludeDirs = stringList{"vendor"}
+
+	from, err := loadSnapshot("HEAD")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer from.close()
+	to, err := loadSnapshot(".")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// Unchanged files have the same hash in both versions, only files in a
+	// known language are read from the directory.
+	if from.hashes["lib/lib.go"] == "" || from.hashes["lib/lib.go"] != to.hashes["lib/lib.go"] {
+		t.Errorf("Expected the same hash for lib/lib.go, got %q and %q",
+			from.hashes["lib/lib.go"], to.hashes["lib/lib.go"])
+	}
+	if _, ok := to.hashes["notes.txt"]; ok {
+		t.Error("Expected notes.txt to not be in the snapshot of the directory")
+	}
+
+	r, err := diffSnapshots(from, to)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var files []string
+	for _, f := range r.Files {
+		files = append(files, f.Status+" "+f.Path)
+	}
+	expected := []string{"removed lib/old.go", "modified main.go"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("Expected changed files %v, got %v", expected, files)
+	}
+
+	expectedTotal := diffStats{
+		Added:     loc.Stats{Code: 2},
+		Removed:   loc.Stats{Files: 1, Blank: 1, Code: 2},
+		Modified:  loc.Stats{Files: 1, Comment: 1},
+		Unchanged: loc.Stats{Files: 2, Blank: 1, Code: 3},
+		Net:       loc.Stats{Files: -1, Blank: -1, Comment: 1, Code: -1},
+	}
+	if r.Total != expectedTotal {
+		t.Errorf("Expected total %+v, got %+v", expectedTotal, r.Total)
+	}
+
+	if _, err := loadSnapshot("no-such-rev"); err == nil {
+		t.Error("Expected an error for an unknown revision")
+	}
+}
+
+func TestDiffFormats(t *testing.T) {
+	r, err := diffSnapshots(
+		memSnapshot(t, map[string]string{"a.go": "package a\n"}),
+		memSnapshot(t, map[string]string{"a.go": "package a\n\nvar b = 1\n"}),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for name, write := range diffFormats {
+		var buf strings.Builder
+		if err := write(&buf, r); err != nil {
+			t.Errorf("Unexpected error writing %s: %s", name, err)
+		} else if !strings.Contains(buf.String(), "a.go") {
+			t.Errorf("Expected the %s output to hold the changed file, got:\n%s",
+				name, buf.String())
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+func TestCountFileEmbedded(t *testing.T) {
+	type test struct {
+		filepath string
+		expected loc.Counts
+	}
+
+	tests := []test{
+		{"page.html", loc.Counts{
+			"html":                          {Files: 1, Comment: 1, Code: 15},
+			"css (embedded in html)":        {Blank: 1, Comment: 1, Code: 1},
+			"javascript (embedded in html)": {Comment: 1, Code: 2},
+		}},
+		{"component.vue", loc.Counts{
+			"vue":                          {Files: 1, Blank: 2, Code: 7},
+			"typescript (embedded in vue)": {Comment: 1, Code: 1},
+			"css (embedded in vue)":        {Code: 3},
+		}},
+		{"App.svelte", loc.Counts{
+			"svelte":                          {Files: 1, Blank: 1, Code: 5},
+			"javascript (embedded in svelte)": {Code: 1},
+		}},
+		{"doc.md", loc.Counts{
+			"markdown":                     {Files: 1, Blank: 4, Comment: 1, Code: 9},
+			"go (embedded in markdown)":    {Comment: 1, Code: 1},
+			"shell (embedded in markdown)": {Code: 1},
+		}},
+	}
+
+	for _, test := range tests {
+		got, err := countFile("_testdata/embedded/" + test.filepath)
+		if err != nil {
+			t.Errorf("Unexpected error: %s", err)
+			continue
+		}
+
+		if len(got) != len(test.expected) {
+			t.Errorf("Expected %s to be counted as %v, got %v", test.filepath,
+				test.expected, got)
+			continue
+		}
+
+		for name, expected := range test.expected {
+			if got[name] == nil || *got[name] != *expected {
+				t.Errorf("Expected %s in %s to be %+v, got %+v", name,
+					test.filepath, expected, got[name])
+			}
+		}
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+// Ways to sort the functions, set using the -sort flag.
+const (
+	sortComplexity = "complexity" // Highest complexity first.
+	sortLines      = "lines"      // Longest functions first.
+	sortName       = "name"       // By function name, e.g. "(*walker).skip".
+)
+
+// FuncReport holds the stats of Go functions, it's the data written in the
+// function mode, set using the -funcs flag.
+type funcReport struct {
+	Version int         `json:"version"`
+	Funcs   []funcStats `json:"funcs"`
+}
+
+// FuncStats holds the stats of a single function or method.
+type funcStats struct {
+	Package    string `json:"package"`
+	Name       string `json:"name"` // E.g. "main" or "(*walker).walk".
+	Path       string `json:"path"`
+	Line       int    `json:"line"`
+	Lines      int    `json:"lines"`
+	Complexity int    `json:"complexity"`
+}
+
+// Position returns the position of the function as path:line.
+func (f funcStats) position() string {
+	return f.Path + ":" + strconv.Itoa(f.Line)
+}
+
+// FuncFormats maps the name of a output format to the function that writes
+// the report in that format, in the function mode.
+var funcFormats = map[string]func(io.Writer, funcReport) error{
+	"table": writeFuncTable,
+	"json":  writeFuncJSON,
+	"csv":   writeFuncCSV,
+}
+
+// MainFuncs finds the Go functions in the paths and writes the report, it's
+// main for the function mode. If any function exceeds the -max-complexity or
+// -max-lines thresholds they're listed on stderr, after any files that
+// couldn't be parsed, and it exits with exitExceeded.
+func mainFuncs(paths []string) {
+	write, ok := funcFormats[format]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Output format %s is not supported with -funcs.\n", format)
+		exit(exitError)
+		return
+	}
+
+	less, ok := funcSorts[funcSort]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown sort order %s.\n", funcSort)
+		exit(exitError)
+		return
+	}
+
+	funcs, err := goFuncs(paths)
+	sort.SliceStable(funcs, func(i, j int) bool { return less(funcs[i], funcs[j]) })
+	exceeded := exceeding(funcs, maxComplexity, maxLines)
+
+	r := funcReport{Version: schemaVersion, Funcs: funcs}
+	if top > 0 && len(r.Funcs) > top {
+		r.Funcs = r.Funcs[:top]
+	}
+
+	if err := write(os.Stdout, r); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %s.\n", err)
+		exit(exitError)
+		return
+	}
+
+	failed, _ := err.(loc.Errors)
+	if len(failed) != 0 {
+		fmt.Fprintf(os.Stderr, "Failed to count %d file(s):\n", len(failed))
+		for _, err := range failed {
+			fmt.Fprintf(os.Stderr, "  %s\n", err)
+		}
+	}
+
+	if len(exceeded) != 0 {
+		fmt.Fprintf(os.Stderr, "%d function(s) exceed the thresholds:\n", len(exceeded))
+		for _, f := range exceeded {
+			fmt.Fprintf(os.Stderr, "  %s (%s): complexity %d, %d lines\n", f.Name,
+				f.position(), f.Complexity, f.Lines)
+		}
+	}
+
+	// The highest exit status wins.
+	if len(exceeded) != 0 {
+		exit(exitExceeded)
+	} else if len(failed) != 0 {
+		exit(exitFailed)
+	}
+}
+
+// FuncSorts maps the sort orders to a less function.
+var funcSorts = map[string]func(a, b funcStats) bool{
+	sortComplexity: func(a, b funcStats) bool {
+		if a.Complexity != b.Complexity {
+			return a.Complexity > b.Complexity
+		}
+		return positionLess(a, b)
+	},
+	sortLines: func(a, b funcStats) bool {
+		if a.Lines != b.Lines {
+			return a.Lines > b.Lines
+		}
+		return positionLess(a, b)
+	},
+	sortName: func(a, b funcStats) bool {
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return positionLess(a, b)
+	},
+}
+
+func positionLess(a, b funcStats) bool {
+	if a.Path != b.Path {
+		return a.Path < b.Path
+	}
+	return a.Line < b.Line
+}
+
+// Exceeding returns the functions with a complexity or number of lines above
+// the maximum, a maximum of 0 means no limit.
+func exceeding(funcs []funcStats, maxComplexity, maxLines int) []funcStats {
+	var exceeded []funcStats
+	for _, f := range funcs {
+		if (maxComplexity > 0 && f.Complexity > maxComplexity) ||
+			(maxLines > 0 && f.Lines > maxLines) {
+			exceeded = append(exceeded, f)
+		}
+	}
+	return exceeded
+}
+
+// GoFuncs returns the stats of all functions and methods in the Go files in
+// the paths, including test files, sorted by position. See goFiles for the
+// files that are used.
+//
+// Errors are returned as loc.Errors, the functions are still valid.
+func goFuncs(paths []string) ([]funcStats, error) {
+	dirs, files, errs := goFiles(paths)
+
+	var funcs []funcStats
+	fset := token.NewFileSet()
+	for _, dir := range dirs {
+		bp, err := build.Default.ImportDir(dir, 0)
+		if _, ok := err.(*build.NoGoError); ok {
+			continue
+		} else if err != nil {
+			errs.Add(err)
+			continue
+		}
+
+		var names []string
+		for _, list := range [][]string{bp.GoFiles, bp.CgoFiles, bp.TestGoFiles,
+			bp.XTestGoFiles} {
+			names = append(names, list...)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if !files[dir][name] {
+				continue
+			}
+
+			path := filepath.Join(dir, name)
+			f, err := parser.ParseFile(fset, path, nil, 0)
+			if err != nil {
+				errs.Add(err)
+				continue
+			}
+			funcs = append(funcs, fileFuncs(fset, path, f)...)
+		}
+	}
+	return funcs, errs.Err()
+}
+
+// FileFuncs returns the stats of the functions and methods declared in the
+// file. Function literals are part of the function they're declared in.
+func fileFuncs(fset *token.FileSet, path string, f *ast.File) []funcStats {
+	var funcs []funcStats
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+
+		start, end := fset.Position(fn.Pos()), fset.Position(fn.End())
+		funcs = append(funcs, funcStats{
+			Package:    f.Name.Name,
+			Name:       funcName(fn),
+			Path:       path,
+			Line:       start.Line,
+			Lines:      end.Line - start.Line + 1,
+			Complexity: complexity(fn),
+		})
+	}
+	return funcs
+}
+
+// FuncName returns the name of the function, including the receiver for
+// methods, e.g. "(*walker).walk".
+func funcName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+
+	recv := fn.Recv.List[0].Type
+	var prefix string
+	if star, ok := recv.(*ast.StarExpr); ok {
+		prefix, recv = "*", star.X
+	}
+
+	// Drop type parameters, e.g. List[T].
+	switch t := recv.(type) {
+	case *ast.IndexExpr:
+		recv = t.X
+	case *ast.IndexListExpr:
+		recv = t.X
+	}
+
+	if ident, ok := recv.(*ast.Ident); ok {
+		return "(" + prefix + ident.Name + ")." + fn.Name.Name
+	}
+	return fn.Name.Name
+}
+
+// Complexity returns the cyclomatic complexity of the function: one plus the
+// number of if, for and range statements, non-default case and select
+// clauses, and && and || operators.
+func complexity(fn *ast.FuncDecl) int {
+	c := 1
+	ast.Inspect(fn, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt:
+			c++
+		case *ast.CaseClause:
+			if n.List != nil {
+				c++
+			}
+		case *ast.CommClause:
+			if n.Comm != nil {
+				c++
+			}
+		case *ast.BinaryExpr:
+			if n.Op == token.LAND || n.Op == token.LOR {
+				c++
+			}
+		}
+		return true
+	})
+	return c
+}
+
+// WriteFuncTable writes the function report as a human readable table.
+func writeFuncTable(w io.Writer, r funcReport) error {
+	const (
+		header    = "%10s %8s  %-32s %s\n"
+		row       = "%10d %8d  %-32s %s\n"
+		separator = "------------------------------------------------------------------------------\n"
+	)
+
+	ew := &errWriter{w: w}
+	ew.printf(header, "Complexity", "Lines", "Function", "Position")
+	ew.printf(separator)
+	for _, f := range r.Funcs {
+		ew.printf(row, f.Complexity, f.Lines, f.Name, f.position())
+	}
+	return ew.err
+}
+
+// WriteFuncJSON writes the function report as a single json object.
+func writeFuncJSON(w io.Writer, r funcReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(r)
+}
+
+// WriteFuncCSV writes the function report as csv, with a header as the first
+// record and a record per function.
+func writeFuncCSV(w io.Writer, r funcReport) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"version", "package", "name", "path", "line", "lines",
+		"complexity"})
+
+	for _, f := range r.Funcs {
+		cw.Write([]string{strconv.Itoa(r.Version), f.Package, f.Name, f.Path,
+			strconv.Itoa(f.Line), strconv.Itoa(f.Lines), strconv.Itoa(f.Complexity)})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
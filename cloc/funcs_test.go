@@ -0,0 +1,210 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestFileFuncs(t *testing.T) {
+	const src = `package main
+
+func simple() {}
+
+func (w *walker) walk() {
+	for i := 0; i < 10; i++ {
+		if i%2 == 0 && i != 4 || i == 5 {
+			continue
+		}
+	}
+}
+
+func (l List[T]) get(i int) T {
+	switch {
+	case i < 0:
+		return l[0]
+	default:
+		return l[i]
+	}
+}
+
+func selects(c chan int) {
+	select {
+	case <-c:
+	case c <- 1:
+	default:
+	}
+	f := func() {
+		for range c {
+		}
+	}
+	f()
+}
+`
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []funcStats{
+		{"main", "simple", "main.go", 3, 1, 1},
+		{"main", "(*walker).walk", "main.go", 5, 7, 5},
+		{"main", "(List).get", "main.go", 13, 8, 2},
+		{"main", "selects", "main.go", 22, 12, 4},
+	}
+
+	if got := fileFuncs(fset, "main.go", f); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected fileFuncs to return %+v, got %+v", expected, got)
+	}
+}
+
+func TestGoFuncs(t *testing.T) {
+	pkg := filepath.Join("_testdata", "gomode", "pkg")
+	expected := []funcStats{
+		{"pkg", "(T).String", filepath.Join(pkg, "pkg.go"), 20, 3, 1},
+		{"pkg", "helper", filepath.Join(pkg, "pkg.go"), 24, 1, 1},
+		{"pkg", "TestString", filepath.Join(pkg, "pkg_test.go"), 5, 5, 2},
+		{"sub", "Sub", filepath.Join(pkg, "sub", "sub.go"), 4, 1, 1},
+		{"sub_test", "TestSub", filepath.Join(pkg, "sub", "sub_test.go"), 5, 1, 1},
+	}
+
+	got, err := goFuncs([]string{filepath.Join("_testdata", "gomode")})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	} else if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected goFuncs to return %+v, got %+v", expected, got)
+	}
+
+	type test struct {
+		order    string
+		expected []string
+	}
+
+	tests := []test{
+		{sortComplexity, []string{"TestString", "(T).String", "helper", "Sub", "TestSub"}},
+		{sortLines, []string{"TestString", "(T).String", "helper", "Sub", "TestSub"}},
+		{sortName, []string{"(T).String", "Sub", "TestString", "TestSub", "helper"}},
+	}
+
+	for _, test := range tests {
+		less := funcSorts[test.order]
+		sort.SliceStable(got, func(i, j int) bool { return less(got[i], got[j]) })
+
+		var names []string
+		for _, f := range got {
+			names = append(names, f.Name)
+		}
+
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("Expected sorting by %s to return %v, got %v", test.order,
+				test.expected, names)
+		}
+	}
+}
+
+func TestExceeding(t *testing.T) {
+	funcs := []funcStats{
+		{Name: "a", Lines: 10, Complexity: 5},
+		{Name: "b", Lines: 50, Complexity: 2},
+		{Name: "c", Lines: 20, Complexity: 12},
+	}
+
+	type test struct {
+		maxComplexity, maxLines int
+		expected                []string
+	}
+
+	tests := []test{
+		{0, 0, nil},
+		{10, 0, []string{"c"}},
+		{0, 20, []string{"b"}},
+		{4, 40, []string{"a", "b", "c"}},
+		{12, 50, nil},
+	}
+
+	for _, test := range tests {
+		var got []string
+		for _, f := range exceeding(funcs, test.maxComplexity, test.maxLines) {
+			got = append(got, f.Name)
+		}
+
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Expected exceeding(%d, %d) to return %v, got %v",
+				test.maxComplexity, test.maxLines, test.expected, got)
+		}
+	}
+}
+
+func TestMainFuncsFailedAndExceeded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"a.go": "package a\n\nfunc f(x int) int {\n\tif x > 0 {\n\t\treturn x\n\t}\n\treturn 0\n}\n",
+		"b.go": "package a\n\nfunc {\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stdout, err := ioutil.TempFile("", "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(stdout.Name())
+	stderr, err := ioutil.TempFile("", "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(stderr.Name())
+
+	oldStdout, oldStderr, oldArgs, oldExit := os.Stdout, os.Stderr, os.Args, exit
+	defer func() {
+		os.Stdout, os.Stderr, os.Args, exit = oldStdout, oldStderr, oldArgs, oldExit
+		funcsMode, maxComplexity = false, 0
+	}()
+
+	exitCode := 0
+	exit = func(code int) { exitCode = code }
+	os.Stdout, os.Stderr = stdout, stderr
+	os.Args = []string{"", "-funcs", "-max-complexity", "1", dir}
+
+	main()
+
+	// Exceeding a threshold is worse than failing to parse a file.
+	if exitCode != exitExceeded {
+		t.Errorf("Expected exit code %d, got %d", exitExceeded, exitCode)
+	}
+
+	output, err := ioutil.ReadFile(stderr.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"Failed to count 1 file(s):\n",
+		"1 function(s) exceed the thresholds:\n  f (" + filepath.Join(dir, "a.go") +
+			":3): complexity 2, 6 lines\n",
+	} {
+		if !strings.Contains(string(output), expected) {
+			t.Errorf("Expected the errors to contain '%s', got '%s'", expected, output)
+		}
+	}
+}
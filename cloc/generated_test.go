@@ -0,0 +1,58 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+func TestCountFileGenerated(t *testing.T) {
+	type test struct {
+		policy   string
+		expected loc.Counts
+	}
+
+	tests := []test{
+		{loc.GeneratedSeparate, loc.Counts{
+			"go (generated)":        {Files: 1, Blank: 5, Comment: 1, Code: 10},
+			"javascript (minified)": {Files: 2, Comment: 1, Code: 2},
+			"binary":                {Files: 1},
+		}},
+		{loc.GeneratedSkip, loc.Counts{}},
+		{loc.GeneratedCount, loc.Counts{
+			"go":         {Files: 1, Blank: 5, Comment: 1, Code: 10},
+			"javascript": {Files: 2, Comment: 1, Code: 2},
+		}},
+	}
+
+	defer func() {
+		counter.Generated = loc.GeneratedSeparate
+	}()
+
+	for _, test := range tests {
+		counter.Generated = test.policy
+
+		got, err := countDir(filepath.Join("_testdata", "generated"))
+		if err != nil {
+			t.Errorf("Unexpected error: %s", err)
+			continue
+		}
+
+		if len(got) != len(test.expected) {
+			t.Errorf("Expected %v, got %v (policy %s)", test.expected, got, test.policy)
+			continue
+		}
+
+		for name, expected := range test.expected {
+			if got[name] == nil || *got[name] != *expected {
+				t.Errorf("Expected %s to be %+v, got %+v (policy %s)", name,
+					expected, got[name], test.policy)
+			}
+		}
+	}
+}
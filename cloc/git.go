@@ -0,0 +1,312 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+// The mode of symbolic links in a git tree, which are not counted.
+const gitModeSymlink = "120000"
+
+// TreeEntry is a single blob in a git tree, as listed by git ls-tree.
+type treeEntry struct {
+	mode   string
+	object string // Hash of the blob.
+	path   string // Relative to the current directory.
+}
+
+// Git runs git in the current directory with the arguments and returns the
+// output. If git fails the error holds the output of stderr.
+func git(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+
+	// Catch both the stdout and stderr. Cmd.Run() only returns the exit status,
+	// which is not not enough to understand what is going on.
+	var buf, errBuf bytes.Buffer
+	cmd.Stdout = &buf
+	cmd.Stderr = &errBuf
+
+	if err := cmd.Run(); err != nil {
+		return nil, errors.New(strings.TrimSpace(errBuf.String()) + ": " + err.Error())
+	}
+
+	return buf.Bytes(), nil
+}
+
+// BlobCache holds the counts of blobs that were counted before, keyed by the
+// hash of the blob and the file name (which is used to detect the language),
+// see cacheKey.
+type blobCache map[string]loc.Counts
+
+func cacheKey(entry treeEntry) string {
+	return entry.object + ":" + filepath.Base(entry.path)
+}
+
+// CountRev counts the number of blank, comment and code lines in the file or
+// directory at path in the git revision rev, without checking it out. The
+// blobs are read using git ls-tree and git cat-file, the path is relative to
+// the current directory. If cache is not nil it's used to skip blobs that
+// were counted before and the counts of new blobs are added to it.
+//
+// The -exclude-dir, -exclude and -max-depth flags are respected. Ignore files
+// are not read, only files in the tree are counted anyway. Symbolic links and
+// submodules are never counted.
+func countRev(rev, path string, cache blobCache) (loc.Counts, error) {
+	entries, err := lsTree(rev, path)
+	if err != nil {
+		return loc.Counts{}, err
+	} else if len(entries) == 0 {
+		return loc.Counts{}, fmt.Errorf("path %s doesn't exist in %s", path, rev)
+	}
+	return countEntries(rev, path, entries, cache)
+}
+
+// CountEntries counts the blobs of the entries, listed by lsTree, see
+// countRev.
+func countEntries(rev, path string, entries []treeEntry, cache blobCache) (loc.Counts, error) {
+	revCounts := loc.Counts{}
+	var uncached []treeEntry
+	for _, entry := range entries {
+		if entry.mode == gitModeSymlink || treeExcluded(path, entry.path) {
+			continue
+		}
+
+		if c, ok := cache[cacheKey(entry)]; ok {
+			revCounts.Add(c)
+			if budgetDirs != nil {
+				budgetDirs.add(entry.path, c)
+			}
+		} else {
+			uncached = append(uncached, entry)
+		}
+	}
+
+	var errs loc.Errors
+	err := catBlobs(uncached, func(entry treeEntry, content []byte) {
+		c, err := countSource(entry.path, bytes.NewReader(content), int64(len(content)))
+		if err != nil {
+			errs.Add(fmt.Errorf("%s:%s: %s", rev, entry.path, err))
+			return
+		}
+
+		if cache != nil {
+			cache[cacheKey(entry)] = c
+		}
+		if budgetDirs != nil {
+			budgetDirs.add(entry.path, c)
+		}
+		revCounts.Add(c)
+	})
+	errs.Add(err)
+	return revCounts, errs.Err()
+}
+
+// LsTree lists all blobs in the tree of rev at path, recursively.
+func lsTree(rev, path string) ([]treeEntry, error) {
+	out, err := git("ls-tree", "-r", "-z", rev, "--", path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []treeEntry
+	for _, line := range strings.Split(string(out), "\x00") {
+		if line == "" {
+			continue
+		}
+
+		// Format: <mode> SP <type> SP <object> TAB <path>.
+		tab := strings.IndexByte(line, '\t')
+		if tab == -1 {
+			return nil, fmt.Errorf("unexpected git ls-tree output %q", line)
+		}
+
+		fields := strings.Fields(line[:tab])
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected git ls-tree output %q", line)
+		}
+
+		// Submodules are commits, not blobs.
+		if fields[1] == "blob" {
+			entries = append(entries, treeEntry{fields[0], fields[2], line[tab+1:]})
+		}
+	}
+	return entries, nil
+}
+
+// TreeExcluded reports whether the path in a tree should not be counted, based
+// on the -exclude-dir, -exclude and -max-depth flags. Root is the path given
+// on the command line.
+func treeExcluded(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		// A single file.
+		return excluded(path, false, nil)
+	}
+
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if maxDepth >= 0 && len(parts) > maxDepth {
+		return true
+	}
+
+	dir := filepath.Clean(root)
+	for _, part := range parts[:len(parts)-1] {
+		dir = filepath.Join(dir, part)
+		if excluded(dir, true, nil) {
+			return true
+		}
+	}
+	return excluded(path, false, nil)
+}
+
+// CatBlobs reads the contents of the blobs of the entries using a single git
+// cat-file --batch process and calls fn for each.
+func catBlobs(entries []treeEntry, fn func(entry treeEntry, content []byte)) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var objects bytes.Buffer
+	for _, entry := range entries {
+		objects.WriteString(entry.object + "\n")
+	}
+
+	cmd := exec.Command("git", "cat-file", "--batch")
+	cmd.Stdin = &objects
+	var errBuf bytes.Buffer
+	cmd.Stderr = &errBuf
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	} else if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	err = readBatch(bufio.NewReader(stdout), entries, fn)
+	if err != nil {
+		// Make sure git doesn't block on writing.
+		io.Copy(ioutil.Discard, stdout)
+	}
+
+	if waitErr := cmd.Wait(); waitErr != nil && err == nil {
+		err = errors.New(strings.TrimSpace(errBuf.String()) + ": " + waitErr.Error())
+	}
+	return err
+}
+
+// ReadBatch reads the output of git cat-file --batch, for each of the entries
+// in order, and calls fn with the contents of each blob.
+func readBatch(r *bufio.Reader, entries []treeEntry, fn func(entry treeEntry, content []byte)) error {
+	for _, entry := range entries {
+		// Format: <object> SP <type> SP <size> LF <contents> LF, or
+		// <object> SP missing LF.
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			return fmt.Errorf("unexpected git cat-file output %q for %s",
+				strings.TrimSpace(header), entry.path)
+		}
+
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return fmt.Errorf("unexpected git cat-file output %q for %s",
+				strings.TrimSpace(header), entry.path)
+		}
+
+		content := make([]byte, size+1) // Including the trailing new line.
+		if _, err := io.ReadFull(r, content); err != nil {
+			return err
+		}
+
+		fn(entry, content[:size])
+	}
+	return nil
+}
+
+// BlobReader reads blobs one at a time from a single git cat-file --batch
+// process, see catBlobs to read a list of blobs at once.
+type blobReader struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	errBuf bytes.Buffer
+}
+
+// NewBlobReader starts the git process, which must be stopped by calling
+// close.
+func newBlobReader() (*blobReader, error) {
+	b := &blobReader{cmd: exec.Command("git", "cat-file", "--batch")}
+	b.cmd.Stderr = &b.errBuf
+
+	stdin, err := b.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := b.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	} else if err := b.cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	b.stdin, b.stdout = stdin, bufio.NewReader(stdout)
+	return b, nil
+}
+
+// Read returns the contents of the blob of the entry.
+func (b *blobReader) read(entry treeEntry) ([]byte, error) {
+	if _, err := io.WriteString(b.stdin, entry.object+"\n"); err != nil {
+		return nil, err
+	}
+
+	var content []byte
+	err := readBatch(b.stdout, []treeEntry{entry}, func(_ treeEntry, c []byte) {
+		content = c
+	})
+	return content, err
+}
+
+// Close stops the git process.
+func (b *blobReader) close() error {
+	b.stdin.Close()
+	// Make sure git doesn't block on writing.
+	io.Copy(ioutil.Discard, b.stdout)
+
+	if err := b.cmd.Wait(); err != nil {
+		return errors.New(strings.TrimSpace(b.errBuf.String()) + ": " + err.Error())
+	}
+	return nil
+}
+
+// GitBlobHash returns the hash git uses for a blob with the contents read from
+// r, size is the size of the contents.
+func gitBlobHash(r io.Reader, size int64) (string, error) {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", size)
+	if n, err := io.Copy(h, r); err != nil {
+		return "", err
+	} else if n != size {
+		return "", fmt.Errorf("size changed from %d to %d bytes while reading", size, n)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
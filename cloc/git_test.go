@@ -0,0 +1,160 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+// testRepo creates a git repository in a temporary directory, with a commit
+// for each of the maps of files, and changes the working directory to it. It
+// returns a function that removes the repository and restores the working
+// directory.
+func testRepo(t *testing.T, commits ...map[string]string) func() {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "cloc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cleanup := func() {
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) {
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"},
+			args...)
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			cleanup()
+			t.Fatalf("Error running git %v: %s: %s", args, err, out)
+		}
+	}
+
+	run("init", "-q")
+	for _, files := range commits {
+		for name, content := range files {
+			path := filepath.FromSlash(name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				cleanup()
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+				cleanup()
+				t.Fatal(err)
+			}
+		}
+		run("add", "-A")
+		run("commit", "-q", "-m", "commit")
+	}
+	return cleanup
+}
+
+func TestCountRev(t *testing.T) {
+	defer testRepo(t,
+		map[string]string{
+			"main.go":         "package main\n\n// Comment\nfunc main() {}\n",
+			"lib/lib.go":      "package lib\n",
+			"lib/deep/x.go":   "package deep\n",
+			"vendor/v.go":     "package v\n",
+			"web/app.js":      "var a = 1;\n",
+			"README":          "Not counted.\n",
+			".gitignore":      "ignored.go\n",
+			"ignored/file.go": "package ignored\n",
+		},
+		map[string]string{
+			"main.go": "package main\n",
+		},
+	)()
+
+	// Changes in the working tree are not counted.
+	if err := ioutil.WriteFile("lib/lib.go", []byte("\n\n\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("main.go", "link.go"); err != nil {
+		t.Fatal(err)
+	}
+
+	type test struct {
+		rev, path   string
+		excludeDirs stringList
+		maxDepth    int
+		expected    loc.Counts
+	}
+
+	tests := []test{
+		{"HEAD", ".", nil, -1, loc.Counts{"go": {Files: 5, Code: 5}, "javascript": {Files: 1, Code: 1}}},
+		{"HEAD~1", ".", nil, -1, loc.Counts{"go": {Files: 5, Blank: 1, Comment: 1, Code: 6}, "javascript": {Files: 1, Code: 1}}},
+		{"HEAD", "lib", nil, -1, loc.Counts{"go": {Files: 2, Code: 2}}},
+		{"HEAD", "main.go", nil, -1, loc.Counts{"go": {Files: 1, Code: 1}}},
+		{"HEAD", ".", stringList{"vendor", "lib/deep"}, -1,
+			loc.Counts{"go": {Files: 3, Code: 3}, "javascript": {Files: 1, Code: 1}}},
+		{"HEAD", ".", nil, 1, loc.Counts{"go": {Files: 1, Code: 1}}},
+		{"HEAD", "lib", nil, 1, loc.Counts{"go": {Files: 1, Code: 1}}},
+	}
+
+	defer func() {
+		excludeDirs, maxDepth = nil, -1
+	}()
+
+	for _, test := range tests {
+		excludeDirs, maxDepth = test.excludeDirs, test.maxDepth
+
+		got, err := countRev(test.rev, test.path, nil)
+		if err != nil {
+			t.Errorf("Unexpected error: %s", err)
+			continue
+		}
+
+		if len(got) != len(test.expected) {
+			t.Errorf("Expected countRev(%s, %s) to return %v, got %v", test.rev,
+				test.path, test.expected, got)
+			continue
+		}
+
+		for name, expected := range test.expected {
+			if got[name] == nil || *got[name] != *expected {
+				t.Errorf("Expected %s in countRev(%s, %s) to be %+v, got %+v", name,
+					test.rev, test.path, expected, got[name])
+			}
+		}
+	}
+}
+
+func TestCountRevErrors(t *testing.T) {
+	defer testRepo(t, map[string]string{"main.go": "package main\n"})()
+
+	tests := []struct {
+		rev, path string
+	}{
+		{"HEAD", "not_found"},
+		{"unknown", "."},
+	}
+
+	for _, test := range tests {
+		if _, err := countRev(test.rev, test.path, nil); err == nil {
+			t.Errorf("Expected an error for countRev(%s, %s)", test.rev, test.path)
+		}
+	}
+}
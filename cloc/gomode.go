@@ -0,0 +1,337 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+// GoReport holds the counts of all Go packages found, it's the data written
+// in the Go mode, set using the -go flag.
+type goReport struct {
+	Version  int         `json:"version"`
+	Packages []goPackage `json:"packages"`
+	Total    goPackage   `json:"total"`
+}
+
+// GoPackage holds the counts of a single Go package, split between test and
+// non-test files.
+type goPackage struct {
+	Path string  `json:"path,omitempty"` // Directory of the package.
+	Name string  `json:"name,omitempty"`
+	Code goStats `json:"code"`
+	Test goStats `json:"test"`
+	// The number of code lines in test files per code line in other files.
+	Ratio float64 `json:"test_ratio"`
+}
+
+// GoStats holds the line counts and the number of declarations in Go files.
+type goStats struct {
+	loc.Stats
+	Funcs    int `json:"funcs"`    // Functions and methods.
+	Types    int `json:"types"`    // Top level types.
+	Exported int `json:"exported"` // Top level exported identifiers.
+}
+
+func (s *goStats) add(other goStats) {
+	s.Stats.Add(other.Stats)
+	s.Funcs += other.Funcs
+	s.Types += other.Types
+	s.Exported += other.Exported
+}
+
+// Add adds the package to the report, updating the total.
+func (r *goReport) add(pkg goPackage) {
+	r.Version = schemaVersion
+	r.Packages = append(r.Packages, pkg)
+	r.Total.Code.add(pkg.Code)
+	r.Total.Test.add(pkg.Test)
+	r.Total.Ratio = testRatio(r.Total.Code, r.Total.Test)
+}
+
+func testRatio(code, test goStats) float64 {
+	if code.Code == 0 {
+		return 0
+	}
+	return float64(test.Code) / float64(code.Code)
+}
+
+// GoFormats maps the name of a output format to the function that writes the
+// report in that format, in the Go mode.
+var goFormats = map[string]func(io.Writer, goReport) error{
+	"table": writeGoTable,
+	"json":  writeGoJSON,
+	"csv":   writeGoCSV,
+}
+
+// MainGo counts the Go packages in the paths and writes the report, it's
+// main for the Go mode.
+func mainGo(paths []string) {
+	write, ok := goFormats[format]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Output format %s is not supported with -go.\n", format)
+		exit(exitError)
+		return
+	}
+
+	r, err := countGo(paths)
+	if err := write(os.Stdout, r); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %s.\n", err)
+		exit(exitError)
+		return
+	}
+
+	if failed, ok := err.(loc.Errors); ok {
+		fmt.Fprintf(os.Stderr, "Failed to count %d file(s):\n", len(failed))
+		for _, err := range failed {
+			fmt.Fprintf(os.Stderr, "  %s\n", err)
+		}
+		exit(exitFailed)
+	}
+}
+
+// CountGo counts the Go packages in the paths, sorted by directory, see
+// goFiles for the files that are counted.
+//
+// Errors are returned as loc.Errors, the counts are still valid.
+func countGo(paths []string) (goReport, error) {
+	dirs, files, errs := goFiles(paths)
+
+	var r goReport
+	r.Version = schemaVersion
+	for _, dir := range dirs {
+		pkg, ok, err := countGoPackage(dir, files[dir])
+		errs.Add(err)
+		if ok {
+			r.add(pkg)
+		}
+	}
+	return r, errs.Err()
+}
+
+// GoFiles returns the sorted directories holding Go files in the paths and
+// the names of the Go files per directory. Given directories are walked like
+// countDir does, but like the go tool directories named testdata or vendor,
+// or starting with . or _, are skipped. Given files are counted as part of
+// the package in their directory.
+//
+// Which of the files are part of the package, based on the build
+// constraints, is determined by build.ImportDir.
+func goFiles(paths []string) ([]string, map[string]map[string]bool, loc.Errors) {
+	var errs loc.Errors
+	files := map[string]map[string]bool{} // Directory -> file names.
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		info, err := os.Stat(path)
+		if err != nil {
+			errs.Add(err)
+			continue
+		}
+
+		if !info.IsDir() {
+			addGoFile(files, path)
+			continue
+		}
+
+		errs.Add(walkDir(path, func(file string) {
+			if rel, err := filepath.Rel(path, filepath.Dir(file)); err == nil &&
+				!goSkipDir(rel) {
+				addGoFile(files, file)
+			}
+		}))
+	}
+
+	var dirs []string
+	for dir := range files {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs, files, errs
+}
+
+// AddGoFile adds the path to the files of its directory, if it's a Go file.
+func addGoFile(dirs map[string]map[string]bool, path string) {
+	if filepath.Ext(path) != ".go" {
+		return
+	}
+
+	dir := filepath.Dir(path)
+	if dirs[dir] == nil {
+		dirs[dir] = map[string]bool{}
+	}
+	dirs[dir][filepath.Base(path)] = true
+}
+
+// GoSkipDir reports whether the go tool would skip the directory, relative to
+// the directory given on the command line.
+func goSkipDir(rel string) bool {
+	if rel == "." {
+		return false
+	}
+
+	for _, name := range strings.Split(filepath.ToSlash(rel), "/") {
+		if name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") ||
+			strings.HasPrefix(name, "_") {
+			return true
+		}
+	}
+	return false
+}
+
+// CountGoPackage counts the files of the package in the directory, only
+// files that are in files are counted. It returns false if the directory
+// doesn't hold any Go files for the current platform.
+func countGoPackage(dir string, files map[string]bool) (goPackage, bool, error) {
+	bp, err := build.Default.ImportDir(dir, 0)
+	if _, ok := err.(*build.NoGoError); ok {
+		return goPackage{}, false, nil
+	} else if err != nil {
+		return goPackage{}, false, err
+	}
+
+	pkg := goPackage{Path: dir, Name: bp.Name}
+	fset := token.NewFileSet()
+
+	var errs loc.Errors
+	count := func(names []string, s *goStats) {
+		for _, name := range names {
+			if !files[name] {
+				continue
+			}
+
+			fileStats, err := countGoFile(fset, filepath.Join(dir, name))
+			errs.Add(err)
+			s.add(fileStats)
+		}
+	}
+
+	count(append(bp.GoFiles, bp.CgoFiles...), &pkg.Code)
+	count(append(bp.TestGoFiles, bp.XTestGoFiles...), &pkg.Test)
+	pkg.Ratio = testRatio(pkg.Code, pkg.Test)
+
+	if pkg.Code.Files == 0 && pkg.Test.Files == 0 {
+		return goPackage{}, false, errs.Err()
+	}
+	return pkg, true, errs.Err()
+}
+
+// CountGoFile counts the lines and declarations in a single Go file. If the
+// file can't be parsed the lines are still counted.
+func countGoFile(fset *token.FileSet, path string) (goStats, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return goStats{}, err
+	}
+
+	// Reading from a bytes.Reader never fails.
+	lines, _ := loc.Classify(bytes.NewReader(src), counter.Languages["go"])
+	s := goStats{Stats: lines}
+	s.Files = 1
+
+	f, err := parser.ParseFile(fset, path, src, 0)
+	if err != nil {
+		return s, err
+	}
+
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			s.Funcs++
+			if decl.Name.IsExported() {
+				s.Exported++
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					s.Types++
+					if spec.Name.IsExported() {
+						s.Exported++
+					}
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						if name.IsExported() {
+							s.Exported++
+						}
+					}
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+// WriteGoTable writes the Go report as a human readable table, with a row per
+// package followed by the total. The funcs, types and exported columns only
+// count non-test files.
+func writeGoTable(w io.Writer, r goReport) error {
+	const (
+		header    = "%-32s %6s %6s %8s %8s %6s %6s %8s %6s\n"
+		row       = "%-32s %6d %6d %8d %8d %6d %6d %8d %6.2f\n"
+		separator = "-----------------------------------------------------------------------------------------------\n"
+	)
+
+	ew := &errWriter{w: w}
+	ew.printf(header, "Package", "Files", "Tests", "Code", "Test", "Funcs",
+		"Types", "Exported", "Ratio")
+	ew.printf(separator)
+	for _, pkg := range r.Packages {
+		ew.printf(row, pkg.Path, pkg.Code.Files, pkg.Test.Files, pkg.Code.Code,
+			pkg.Test.Code, pkg.Code.Funcs, pkg.Code.Types, pkg.Code.Exported, pkg.Ratio)
+	}
+	ew.printf(separator)
+
+	t := r.Total
+	ew.printf(row, "Total", t.Code.Files, t.Test.Files, t.Code.Code, t.Test.Code,
+		t.Code.Funcs, t.Code.Types, t.Code.Exported, t.Ratio)
+	return ew.err
+}
+
+// WriteGoJSON writes the Go report as a single json object.
+func writeGoJSON(w io.Writer, r goReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(r)
+}
+
+// WriteGoCSV writes the Go report as csv, with a header as the first record.
+// Each package has two records, one for non-test and one for test files. The
+// records with an empty path hold the total of all packages.
+func writeGoCSV(w io.Writer, r goReport) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"version", "path", "package", "test", "files", "blank",
+		"comment", "code", "funcs", "types", "exported"})
+
+	record := func(pkg goPackage, test bool, s goStats) {
+		cw.Write([]string{strconv.Itoa(r.Version), pkg.Path, pkg.Name,
+			strconv.FormatBool(test), strconv.Itoa(s.Files), strconv.Itoa(s.Blank),
+			strconv.Itoa(s.Comment), strconv.Itoa(s.Code), strconv.Itoa(s.Funcs),
+			strconv.Itoa(s.Types), strconv.Itoa(s.Exported)})
+	}
+
+	for _, pkg := range append(r.Packages, r.Total) {
+		record(pkg, false, pkg.Code)
+		record(pkg, true, pkg.Test)
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
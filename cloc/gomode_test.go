@@ -0,0 +1,122 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+func TestGoSkipDir(t *testing.T) {
+	type test struct {
+		rel      string
+		expected bool
+	}
+
+	tests := []test{
+		{".", false},
+		{"pkg", false},
+		{"pkg/sub", false},
+		{"testdata", true},
+		{"pkg/testdata/sub", true},
+		{"vendor/lib", true},
+		{"_example", true},
+		{".hidden", true},
+		{"pkg/my_pkg", false},
+	}
+
+	for _, test := range tests {
+		if got := goSkipDir(filepath.FromSlash(test.rel)); got != test.expected {
+			t.Errorf("Expected goSkipDir(%s) to return %t, got %t", test.rel,
+				test.expected, got)
+		}
+	}
+}
+
+func TestCountGo(t *testing.T) {
+	pkgPath := filepath.Join("_testdata", "gomode", "pkg")
+	pkg := goPackage{
+		Path:  pkgPath,
+		Name:  "pkg",
+		Code:  goStats{loc.Stats{Files: 1, Blank: 7, Comment: 4, Code: 13}, 2, 2, 4},
+		Test:  goStats{loc.Stats{Files: 1, Blank: 2, Code: 7}, 1, 0, 1},
+		Ratio: 7.0 / 13.0,
+	}
+	sub := goPackage{
+		Path:  filepath.Join(pkgPath, "sub"),
+		Name:  "sub",
+		Code:  goStats{loc.Stats{Files: 1, Blank: 1, Comment: 1, Code: 2}, 1, 0, 1},
+		Test:  goStats{loc.Stats{Files: 1, Blank: 2, Code: 3}, 1, 0, 1},
+		Ratio: 1.5,
+	}
+
+	type test struct {
+		paths    []string
+		expected []goPackage
+	}
+
+	tests := []test{
+		{[]string{filepath.Join("_testdata", "gomode")}, []goPackage{pkg, sub}},
+		{[]string{filepath.Join(pkgPath, "sub")}, []goPackage{sub}},
+		{[]string{filepath.Join(pkgPath, "sub", "sub.go")}, []goPackage{{
+			Path: sub.Path,
+			Name: "sub",
+			Code: sub.Code,
+		}}},
+		{[]string{filepath.Join(pkgPath, "testdata")}, []goPackage{{
+			Path: filepath.Join(pkgPath, "testdata"),
+			Name: "data",
+			Code: goStats{Stats: loc.Stats{Files: 1, Code: 1}},
+		}}},
+		{[]string{filepath.Join("_testdata", "file.c")}, nil},
+	}
+
+	for _, test := range tests {
+		r, err := countGo(test.paths)
+		if err != nil {
+			t.Errorf("Unexpected error: %s", err)
+			continue
+		}
+
+		if !reflect.DeepEqual(r.Packages, test.expected) {
+			t.Errorf("Expected countGo(%v) to return %+v, got %+v", test.paths,
+				test.expected, r.Packages)
+		}
+	}
+}
+
+func TestCountGoErrors(t *testing.T) {
+	_, err := countGo([]string{filepath.Join("_testdata", "not_found")})
+	if list, ok := err.(loc.Errors); !ok || len(list) != 1 {
+		t.Errorf("Expected a single error, got %v", err)
+	}
+}
+
+func TestWriteGoTable(t *testing.T) {
+	r, err := countGo([]string{filepath.Join("_testdata", "gomode")})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeGoTable(&buf, r); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := `Package                           Files  Tests     Code     Test  Funcs  Types Exported  Ratio
+-----------------------------------------------------------------------------------------------
+` + filepath.Join("_testdata", "gomode", "pkg") + `                  1      1       13        7      2      2        4   0.54
+` + filepath.Join("_testdata", "gomode", "pkg", "sub") + `              1      1        2        3      1      0        1   1.50
+-----------------------------------------------------------------------------------------------
+Total                                 2      2       15       10      3      2        5   0.67
+`
+	if got := buf.String(); got != expected {
+		t.Errorf("Expected the table to be\n%s\ngot\n%s", expected, got)
+	}
+}
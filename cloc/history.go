@@ -0,0 +1,276 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+// Which commits are counted in the history mode, set using the -history
+// flag.
+const (
+	historyAll  = "all"  // Every commit.
+	historyDay  = "day"  // The last commit of each day.
+	historyWeek = "week" // The last commit of each (ISO) week.
+	historyTag  = "tag"  // Every tagged commit.
+)
+
+// HistoryReport holds the counts of a series of commits, oldest first, it's
+// the data written in the history mode.
+type historyReport struct {
+	Version int            `json:"version"`
+	Commits []historyPoint `json:"commits"`
+}
+
+// HistoryPoint holds the counts of all paths in a single commit.
+type historyPoint struct {
+	Commit    string              `json:"commit"`
+	Date      time.Time           `json:"date"`
+	Tags      []string            `json:"tags,omitempty"`
+	Languages []loc.LanguageStats `json:"languages"`
+	Total     loc.Stats           `json:"total"`
+}
+
+// Commit is a single commit, as listed by gitCommits.
+type commit struct {
+	hash string
+	date time.Time // In the local time zone, see gitCommits.
+	tags []string
+}
+
+// HistoryFormats maps the name of a output format to the function that
+// writes the report in that format, in the history mode.
+var historyFormats = map[string]func(io.Writer, historyReport) error{
+	"table": writeHistoryTable,
+	"json":  writeHistoryJSON,
+	"csv":   writeHistoryCSV,
+}
+
+// MainHistory counts the paths in the commits selected by the -history and
+// -every flags and writes the report, it's main for the history mode.
+func mainHistory(paths []string) {
+	write, ok := historyFormats[format]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Output format %s is not supported with -history.\n", format)
+		exit(exitError)
+		return
+	}
+
+	if every < 1 {
+		fmt.Fprintf(os.Stderr, "Invalid -every %d, must be at least 1.\n", every)
+		exit(exitError)
+		return
+	}
+
+	start := rev
+	if start == "" {
+		start = "HEAD"
+	}
+
+	commits, err := gitCommits(start)
+	if err == nil {
+		commits, err = selectCommits(commits, history, every)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing commits: %s.\n", err)
+		exit(exitError)
+		return
+	}
+
+	r, err := countHistory(commits, paths)
+	if err := write(os.Stdout, r); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %s.\n", err)
+		exit(exitError)
+		return
+	}
+
+	if failed, ok := err.(loc.Errors); ok {
+		fmt.Fprintf(os.Stderr, "Failed to count %d file(s):\n", len(failed))
+		for _, err := range failed {
+			fmt.Fprintf(os.Stderr, "  %s\n", err)
+		}
+		exit(exitFailed)
+	}
+}
+
+// GitCommits lists the commits on the first parent line of rev, i.e. the
+// mainline without the commits of merged branches, oldest first.
+func gitCommits(rev string) ([]commit, error) {
+	out, err := git("log", "--first-parent", "--reverse", "-z",
+		"--format=%H%x1f%cI%x1f%D", rev, "--")
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []commit
+	for _, record := range strings.Split(string(out), "\x00") {
+		if record = strings.TrimSpace(record); record == "" {
+			continue
+		}
+
+		fields := strings.Split(record, "\x1f")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected git log output %q", record)
+		}
+
+		date, err := time.Parse(time.RFC3339, fields[1])
+		if err != nil {
+			return nil, err
+		}
+
+		// The commits are grouped by day or week and shown in the local time
+		// zone, not the time zone of the committer, see selectCommits.
+		c := commit{hash: fields[0], date: date.In(time.Local)}
+		for _, ref := range strings.Split(fields[2], ", ") {
+			if strings.HasPrefix(ref, "tag: ") {
+				c.tags = append(c.tags, strings.TrimPrefix(ref, "tag: "))
+			}
+		}
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
+
+// SelectCommits selects the commits to count, based on the history mode, and
+// then every nth of those, always including the newest. Days and weeks are
+// in the time zone of the commit dates, the same dates as shown in the
+// output.
+func selectCommits(commits []commit, mode string, every int) ([]commit, error) {
+	var key func(c commit) string
+	switch mode {
+	case historyAll:
+	case historyDay:
+		key = func(c commit) string {
+			return c.date.Format("2006-01-02")
+		}
+	case historyWeek:
+		key = func(c commit) string {
+			year, week := c.date.ISOWeek()
+			return fmt.Sprintf("%d-%d", year, week)
+		}
+	case historyTag:
+	default:
+		return nil, fmt.Errorf("unknown history mode %s", mode)
+	}
+
+	var selected []commit
+	for i, c := range commits {
+		switch {
+		case mode == historyTag && len(c.tags) == 0:
+			continue
+		case key != nil && i+1 < len(commits) && key(commits[i+1]) == key(c):
+			// Not the last commit of the period.
+			continue
+		}
+		selected = append(selected, c)
+	}
+
+	var result []commit
+	for i, c := range selected {
+		if (len(selected)-1-i)%every == 0 {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+// CountHistory counts the paths in each of the commits, see countRev. A path
+// that doesn't exist in a commit isn't counted. The counts of blobs are
+// cached, so only new or changed files are counted for each commit.
+func countHistory(commits []commit, paths []string) (historyReport, error) {
+	r := historyReport{Version: schemaVersion}
+	cache := blobCache{}
+
+	var errs loc.Errors
+	for _, c := range commits {
+		commitCounts := loc.Counts{}
+		for _, path := range paths {
+			entries, err := lsTree(c.hash, path)
+			if err != nil {
+				errs.Add(err)
+				continue
+			}
+
+			pathCounts, err := countEntries(c.hash, path, entries, cache)
+			errs.Add(err)
+			commitCounts.Add(pathCounts)
+		}
+
+		r.Commits = append(r.Commits, historyPoint{
+			Commit:    c.hash,
+			Date:      c.date,
+			Tags:      c.tags,
+			Languages: commitCounts.Sorted(),
+			Total:     commitCounts.Total(),
+		})
+	}
+	return r, errs.Err()
+}
+
+// WriteHistoryTable writes the history report as a human readable table, with
+// the total of each commit.
+func writeHistoryTable(w io.Writer, r historyReport) error {
+	const (
+		header    = "%-10s %-12s %8s %8s %8s %8s  %s\n"
+		row       = "%-10s %-12s %8d %8d %8d %8d  %s\n"
+		separator = "----------------------------------------------------------------------\n"
+	)
+
+	ew := &errWriter{w: w}
+	ew.printf(header, "Date", "Commit", "Files", "Blank", "Comment", "Code", "Tags")
+	ew.printf(separator)
+	for _, c := range r.Commits {
+		hash := c.Commit
+		if len(hash) > 12 {
+			hash = hash[:12]
+		}
+		ew.printf(row, c.Date.Format("2006-01-02"), hash, c.Total.Files,
+			c.Total.Blank, c.Total.Comment, c.Total.Code, strings.Join(c.Tags, ", "))
+	}
+	return ew.err
+}
+
+// WriteHistoryJSON writes the history report as a single json object.
+func writeHistoryJSON(w io.Writer, r historyReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(r)
+}
+
+// WriteHistoryCSV writes the history report as csv, with a header as the
+// first record. Each record holds the stats of a single language in a single
+// commit, a record with an empty language holds the total of the commit.
+// Multiple tags are separated by a space.
+func writeHistoryCSV(w io.Writer, r historyReport) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"version", "commit", "date", "tags", "language", "files",
+		"blank", "comment", "code"})
+
+	for _, c := range r.Commits {
+		record := func(language string, s loc.Stats) {
+			cw.Write([]string{strconv.Itoa(r.Version), c.Commit,
+				c.Date.Format(time.RFC3339), strings.Join(c.Tags, " "), language,
+				strconv.Itoa(s.Files), strconv.Itoa(s.Blank), strconv.Itoa(s.Comment),
+				strconv.Itoa(s.Code)})
+		}
+
+		for _, s := range c.Languages {
+			record(s.Language, s.Stats)
+		}
+		record("", c.Total)
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
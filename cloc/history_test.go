@@ -0,0 +1,153 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os/exec"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+func TestSelectCommits(t *testing.T) {
+	day := func(d, hour int) time.Time {
+		return time.Date(2015, time.June, d, hour, 0, 0, 0, time.UTC)
+	}
+
+	// June 1st 2015 is a Monday.
+	commits := []commit{
+		{"a", day(1, 10), nil},
+		{"b", day(1, 12), []string{"v1.0"}},
+		{"c", day(2, 10), nil},
+		{"d", day(3, 10), nil},
+		{"e", day(8, 10), []string{"v1.1", "latest"}},
+		{"f", day(8, 11), nil},
+	}
+
+	type test struct {
+		mode     string
+		every    int
+		expected []string
+	}
+
+	tests := []test{
+		{historyAll, 1, []string{"a", "b", "c", "d", "e", "f"}},
+		{historyAll, 2, []string{"b", "d", "f"}},
+		{historyAll, 4, []string{"b", "f"}},
+		{historyDay, 1, []string{"b", "c", "d", "f"}},
+		{historyWeek, 1, []string{"d", "f"}},
+		{historyTag, 1, []string{"b", "e"}},
+		{historyTag, 10, []string{"e"}},
+	}
+
+	for _, test := range tests {
+		got, err := selectCommits(commits, test.mode, test.every)
+		if err != nil {
+			t.Errorf("Unexpected error: %s", err)
+			continue
+		}
+
+		var hashes []string
+		for _, c := range got {
+			hashes = append(hashes, c.hash)
+		}
+
+		if !reflect.DeepEqual(hashes, test.expected) {
+			t.Errorf("Expected selectCommits(%s, %d) to return %v, got %v",
+				test.mode, test.every, test.expected, hashes)
+		}
+	}
+
+	if _, err := selectCommits(commits, "month", 1); err == nil {
+		t.Error("Expected an error for an unknown history mode")
+	}
+
+	// Commits on different days in their time zone, but on the same day in
+	// UTC.
+	zone := time.FixedZone("UTC+2", 2*60*60)
+	commits = []commit{
+		{"a", time.Date(2015, time.June, 1, 23, 30, 0, 0, zone), nil},
+		{"b", time.Date(2015, time.June, 2, 1, 0, 0, 0, zone), nil},
+	}
+	got, err := selectCommits(commits, historyDay, 1)
+	if err != nil || len(got) != 2 {
+		t.Errorf("Expected both commits to be selected, got %v, %v", got, err)
+	}
+}
+
+func TestCountHistory(t *testing.T) {
+	defer testRepo(t,
+		map[string]string{"main.go": "package main\n"},
+		map[string]string{"lib/lib.go": "package lib\n\n// Lib.\n"},
+		map[string]string{"main.go": "package main\n\nfunc main() {}\n"},
+	)()
+
+	if out, err := exec.Command("git", "tag", "v1", "HEAD~1").CombinedOutput(); err != nil {
+		t.Fatalf("Error tagging: %s: %s", err, out)
+	}
+
+	commits, err := gitCommits("HEAD")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	} else if len(commits) != 3 {
+		t.Fatalf("Expected 3 commits, got %d", len(commits))
+	} else if !reflect.DeepEqual(commits[1].tags, []string{"v1"}) {
+		t.Errorf("Expected the second commit to be tagged v1, got %v", commits[1].tags)
+	} else if commits[0].date.Location() != time.Local {
+		t.Errorf("Expected the commit dates in the local time zone, got %s",
+			commits[0].date.Location())
+	}
+
+	r, err := countHistory(commits, []string{".", "lib"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// Lib is counted twice in the last two commits and doesn't exist in the
+	// first.
+	expected := []loc.Stats{{Files: 1, Code: 1}, {Files: 3, Blank: 2, Comment: 2, Code: 3}, {Files: 3, Blank: 3, Comment: 2, Code: 4}}
+	if len(r.Commits) != len(expected) {
+		t.Fatalf("Expected %d commits, got %d", len(expected), len(r.Commits))
+	}
+
+	for i, c := range r.Commits {
+		if c.Commit != commits[i].hash || c.Total != expected[i] {
+			t.Errorf("Expected commit %s to have %+v, got %s with %+v",
+				commits[i].hash, expected[i], c.Commit, c.Total)
+		}
+	}
+}
+
+func TestCountEntriesCache(t *testing.T) {
+	defer testRepo(t, map[string]string{
+		"main.go": "package main\n",
+		"lib.go":  "package lib\n",
+	})()
+
+	entries, err := lsTree("HEAD", ".")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// Cached blobs are not read again.
+	cache := blobCache{}
+	cache[cacheKey(entries[0])] = loc.Counts{"cached": {Files: 1, Blank: 2, Comment: 3, Code: 4}}
+
+	got, err := countEntries("HEAD", ".", entries, cache)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := loc.Counts{"cached": {Files: 1, Blank: 2, Comment: 3, Code: 4}, "go": {Files: 1, Code: 1}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	if len(cache) != 2 || cache[cacheKey(entries[1])]["go"] == nil {
+		t.Errorf("Expected the counted blob to be cached, got %v", cache)
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// The names of the files holding ignore patterns, read in each directory. The
+// patterns in later files take precedence.
+var ignoreFiles = []string{".gitignore", ".ignore"}
+
+// Version control directories are never counted.
+var vcsDirs = []string{".git", ".hg", ".svn", ".bzr"}
+
+// StringList is a flag.Value that collects a list of strings, the flag can be
+// used multiple times and each value can hold multiple comma separated items.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// Excluded reports whether the path should not be counted, based on the
+// -exclude-dir and -exclude flags and the ignore rules.
+func excluded(p string, isDir bool, rules *ignoreRules) bool {
+	name := filepath.Base(p)
+	slashPath := filepath.ToSlash(filepath.Clean(p))
+
+	if isDir {
+		for _, dir := range vcsDirs {
+			if name == dir {
+				return true
+			}
+		}
+
+		for _, dir := range excludeDirs {
+			dir = filepath.ToSlash(filepath.Clean(dir))
+			if name == dir || slashPath == dir {
+				return true
+			}
+		}
+	}
+
+	for _, pattern := range excludePatterns {
+		if strings.Contains(pattern, "/") {
+			if matchGlob(pattern, slashPath) {
+				return true
+			}
+		} else if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return rules.ignored(p, isDir)
+}
+
+// IgnoreRules holds the ignore patterns found in a single directory, linked
+// to the rules of the parent directory.
+type ignoreRules struct {
+	dir      string
+	patterns []ignorePattern
+	parent   *ignoreRules
+}
+
+// IgnorePattern is a single pattern in a gitignore file.
+type ignorePattern struct {
+	glob     string // The glob without a leading ! or trailing slash.
+	negate   bool   // Pattern started with !, re-including a path.
+	dirOnly  bool   // Pattern ended with a slash, only matches directories.
+	anchored bool   // Pattern holds a slash, matched relative to the directory.
+}
+
+// ReadIgnoreRules reads the ignore files in the directory. If the directory
+// doesn't have any ignore files (or -no-ignore is used) it returns the parent
+// rules.
+func readIgnoreRules(dir string, parent *ignoreRules) (*ignoreRules, error) {
+	if noIgnore {
+		return parent, nil
+	}
+
+	var patterns []ignorePattern
+	for _, name := range ignoreFiles {
+		p, err := readIgnoreFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p...)
+	}
+
+	if len(patterns) == 0 {
+		return parent, nil
+	}
+	return &ignoreRules{dir, patterns, parent}, nil
+}
+
+// ReadIgnoreFile reads the patterns of a single ignore file, a not existing
+// file has no patterns.
+func readIgnoreFile(path string) ([]ignorePattern, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []ignorePattern
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if p, ok := parseIgnorePattern(scanner.Text()); ok {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns, scanner.Err()
+}
+
+// ParseIgnorePattern parses a single line of a gitignore file, it returns
+// false if the line doesn't hold a pattern, e.g. an empty line or a comment.
+func parseIgnorePattern(line string) (ignorePattern, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || line[0] == '#' {
+		return ignorePattern{}, false
+	}
+
+	var p ignorePattern
+	if line[0] == '!' {
+		p.negate = true
+		line = line[1:]
+	} else if line[0] == '\\' {
+		// Escaped # or !.
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	if strings.Contains(line, "/") {
+		p.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	if line == "" {
+		return ignorePattern{}, false
+	}
+
+	p.glob = line
+	return p, true
+}
+
+// Ignored reports whether the path is ignored by the rules. Patterns in
+// deeper directories take precedence over those of parent directories and
+// within a directory the last matching pattern wins.
+func (rules *ignoreRules) ignored(p string, isDir bool) bool {
+	for ; rules != nil; rules = rules.parent {
+		rel, err := filepath.Rel(rules.dir, p)
+		if err != nil || rel == ".." ||
+			strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		for i := len(rules.patterns) - 1; i >= 0; i-- {
+			pattern := rules.patterns[i]
+			if pattern.match(rel, isDir) {
+				return !pattern.negate
+			}
+		}
+	}
+	return false
+}
+
+// Match reports whether the pattern matches the slash separated path,
+// relative to the directory of the ignore file.
+func (pattern ignorePattern) match(rel string, isDir bool) bool {
+	if pattern.dirOnly && !isDir {
+		return false
+	}
+
+	if pattern.anchored {
+		return matchGlob(pattern.glob, rel)
+	}
+
+	ok, _ := path.Match(pattern.glob, path.Base(rel))
+	return ok
+}
+
+// MatchGlob matches a slash separated path against a glob, which may contain
+// ** to match zero or more directories.
+func matchGlob(glob, name string) bool {
+	return matchSegments(strings.Split(glob, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(glob, name []string) bool {
+	for len(glob) > 0 {
+		if glob[0] == "**" {
+			// Try to match the rest of the glob against every suffix of the name.
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(glob[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(glob[0], name[0]); !ok {
+			return false
+		}
+		glob, name = glob[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,186 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+func TestMatchGlob(t *testing.T) {
+	type test struct {
+		glob     string
+		name     string
+		expected bool
+	}
+
+	tests := []test{
+		{"foo", "foo", true},
+		{"foo", "bar", false},
+		{"*.go", "main.go", true},
+		{"*.go", "dir/main.go", false},
+		{"dir/*.go", "dir/main.go", true},
+		{"**/main.go", "main.go", true},
+		{"**/main.go", "a/b/main.go", true},
+		{"a/**/main.go", "a/main.go", true},
+		{"a/**/main.go", "a/b/c/main.go", true},
+		{"a/**/main.go", "b/main.go", false},
+		{"a/**", "a/b/c", true},
+	}
+
+	for _, test := range tests {
+		if got := matchGlob(test.glob, test.name); got != test.expected {
+			t.Errorf("Expected matchGlob(%q, %q) to return %t, got %t",
+				test.glob, test.name, test.expected, got)
+		}
+	}
+}
+
+func TestParseIgnorePattern(t *testing.T) {
+	type test struct {
+		line     string
+		expected ignorePattern
+		ok       bool
+	}
+
+	tests := []test{
+		{"", ignorePattern{}, false},
+		{"# Comment", ignorePattern{}, false},
+		{"/", ignorePattern{}, false},
+		{"*.o", ignorePattern{glob: "*.o"}, true},
+		{"*.o  ", ignorePattern{glob: "*.o"}, true},
+		{"\\#file", ignorePattern{glob: "#file"}, true},
+		{"!keep.o", ignorePattern{glob: "keep.o", negate: true}, true},
+		{"build/", ignorePattern{glob: "build", dirOnly: true}, true},
+		{"/root.go", ignorePattern{glob: "root.go", anchored: true}, true},
+		{"doc/*.txt", ignorePattern{glob: "doc/*.txt", anchored: true}, true},
+	}
+
+	for _, test := range tests {
+		got, ok := parseIgnorePattern(test.line)
+		if ok != test.ok || got != test.expected {
+			t.Errorf("Expected parseIgnorePattern(%q) to return %+v, %t, got %+v, %t",
+				test.line, test.expected, test.ok, got, ok)
+		}
+	}
+}
+
+func TestIgnored(t *testing.T) {
+	pattern, _ := parseIgnorePattern("*.go")
+	dir := filepath.Join("root", "sub")
+	rules := &ignoreRules{dir: dir, patterns: []ignorePattern{pattern}}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{filepath.Join(dir, "a.go"), true},
+		{filepath.Join(dir, "..a", "a.go"), true},
+		{filepath.Join(dir, "..", "a.go"), false},
+		{filepath.Join("root", "a.go"), false},
+		{filepath.Join(dir, "a.c"), false},
+	}
+
+	for _, test := range tests {
+		if got := rules.ignored(test.path, false); got != test.expected {
+			t.Errorf("Expected ignored(%q) to return %t, got %t", test.path,
+				test.expected, got)
+		}
+	}
+}
+
+func TestCountDirIgnore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		".gitignore":          "# Build output\nbuild/\n*.gen.go\n!keep.gen.go\n",
+		".ignore":             "tmp.go\n",
+		"main.go":             "package main\n",
+		"tmp.go":              "package main\n",
+		"skip.gen.go":         "package main\n",
+		"keep.gen.go":         "package main\n",
+		"build/out.go":        "package main\n",
+		"sub/.gitignore":      "/local.go\n!skip.gen.go\n",
+		"sub/local.go":        "package sub\n",
+		"sub/skip.gen.go":     "package sub\n",
+		"sub/deep/local.go":   "package deep\n",
+		"vendor/lib/lib.go":   "package lib\n",
+		"node_modules/a.js":   "var a\n",
+		"static/app.min.js":   "var a\n",
+		"static/app.js":       "var a\n",
+		".git/hooks/hook.sh":  "echo\n",
+		"sub/vendor/other.go": "package other\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type test struct {
+		excludeDirs     stringList
+		excludePatterns stringList
+		noIgnore        bool
+		expected        loc.Counts
+	}
+
+	tests := []test{
+		// main.go, keep.gen.go, sub/skip.gen.go, sub/deep/local.go,
+		// vendor/lib/lib.go and sub/vendor/other.go.
+		{nil, nil, false, loc.Counts{"go": {Files: 6, Code: 6}, "javascript": {Files: 3, Code: 3}}},
+		{stringList{"vendor", "node_modules"}, nil, false,
+			loc.Counts{"go": {Files: 4, Code: 4}, "javascript": {Files: 2, Code: 2}}},
+		{stringList{filepath.Join(dir, "vendor")}, stringList{"*.min.js"}, false,
+			loc.Counts{"go": {Files: 5, Code: 5}, "javascript": {Files: 2, Code: 2}}},
+		{nil, stringList{"**/sub/**/*.go"}, false,
+			loc.Counts{"go": {Files: 3, Code: 3}, "javascript": {Files: 3, Code: 3}}},
+		{nil, nil, true, loc.Counts{"go": {Files: 10, Code: 10}, "javascript": {Files: 3, Code: 3}}},
+	}
+
+	// Count static/app.min.js as any other file.
+	counter.Generated = loc.GeneratedCount
+
+	defer func() {
+		excludeDirs, excludePatterns, noIgnore = nil, nil, false
+		counter.Generated = loc.GeneratedSeparate
+	}()
+
+	for _, test := range tests {
+		excludeDirs = test.excludeDirs
+		excludePatterns = test.excludePatterns
+		noIgnore = test.noIgnore
+
+		got, err := countDir(dir)
+		if err != nil {
+			t.Errorf("Unexpected error: %s", err)
+			continue
+		}
+
+		if len(got) != len(test.expected) {
+			t.Errorf("Expected %v, got %v", test.expected, got)
+			continue
+		}
+
+		for name, expected := range test.expected {
+			if got[name] == nil || *got[name] != *expected {
+				t.Errorf("Expected %s to be %+v, got %+v (exclude-dir %v, "+
+					"exclude %v, no-ignore %t)", name, *expected, got[name],
+					test.excludeDirs, test.excludePatterns, test.noIgnore)
+			}
+		}
+	}
+}
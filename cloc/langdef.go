@@ -0,0 +1,76 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// The name of the per repository configuration file, it's looked for in the
+// current directory and its parents, up to the root of the repository. The
+// format is described by Languages.Load in the loc package.
+const configFile = ".cloc.json"
+
+// LoadLanguageDefinitions loads the languages from the per repository
+// configuration file, if any, and then from the file set by the -lang-def
+// flag, if set.
+func loadLanguageDefinitions() error {
+	if path, err := findConfig(); err != nil {
+		return err
+	} else if path != "" {
+		if err := loadLanguageFile(path); err != nil {
+			return err
+		}
+	}
+
+	if langDef != "" {
+		return loadLanguageFile(langDef)
+	}
+	return nil
+}
+
+// FindConfig looks for the configuration file in the current directory and
+// its parents, stopping at the root of the repository (the directory holding
+// .git). It returns an empty path if there is no configuration file.
+func findConfig() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		path := filepath.Join(dir, configFile)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// LoadLanguageFile reads the language definitions from the file and merges
+// them with the languages of the counter.
+func loadLanguageFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := counter.Languages.Load(f); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	return nil
+}
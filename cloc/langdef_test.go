@@ -0,0 +1,107 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+// restoreLanguages returns a function that restores the languages of the
+// counter to the current state.
+func restoreLanguages() func() {
+	old := make(loc.Languages, len(counter.Languages))
+	for name, lang := range counter.Languages {
+		old[name] = lang
+	}
+
+	return func() {
+		counter.Languages = old
+	}
+}
+
+func TestLoadLanguageFile(t *testing.T) {
+	defer restoreLanguages()()
+
+	dir, err := ioutil.TempDir("", "cloc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "languages.json")
+	def := `{"languages": {"mydsl": {"extensions": ["dsl"], "line_comments": ["--"]}}}`
+	if err := ioutil.WriteFile(path, []byte(def), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := loadLanguageFile(path); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if name, _ := counter.Languages.ByPath("file.dsl"); name != "mydsl" {
+		t.Errorf("Expected file.dsl to be mydsl, got %q", name)
+	}
+
+	// Errors hold the path of the file.
+	if err := ioutil.WriteFile(path, []byte(`{"languages": {"x": null}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expected := path + ": language x: missing definition"
+	if err := loadLanguageFile(path); err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q, got %v", expected, err)
+	}
+}
+
+func TestFindConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Resolve symbolic links in the temporary directory, e.g. on macOS.
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	sub := filepath.Join(dir, "repo", "sub")
+	if err := os.MkdirAll(filepath.Join(dir, "repo", ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	if err := os.Chdir(sub); err != nil {
+		t.Fatal(err)
+	}
+
+	// Configuration files outside the repository are ignored.
+	outside := filepath.Join(dir, configFile)
+	if err := ioutil.WriteFile(outside, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := findConfig(); err != nil || path != "" {
+		t.Errorf("Expected no configuration file, got %q, %v", path, err)
+	}
+
+	inside := filepath.Join(dir, "repo", configFile)
+	if err := ioutil.WriteFile(inside, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := findConfig(); err != nil || path != inside {
+		t.Errorf("Expected configuration file %s, got %q, %v", inside, path, err)
+	}
+}
@@ -0,0 +1,368 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+// Package loc counts the number of blank, comment and code lines in source
+// files, per language. It's the library behind the cloc command, for example:
+//
+//	c := loc.NewCounter()
+//	counts, err := c.CountFS(ctx, os.DirFS("."), ".", nil)
+//	if err != nil {
+//		// Handle the error, counts still holds all files that could be counted.
+//	}
+//	for _, s := range counts.Sorted() {
+//		fmt.Println(s.Language, s.Code)
+//	}
+//
+// The language of a file is detected by its name, modelines and shebang, see
+// Languages.Detect. Custom languages can be added to the Languages of a
+// Counter, see Languages.Load.
+package loc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"runtime"
+	"sync"
+)
+
+// Counter counts the lines of source files. The zero value is ready to use
+// and counts the builtin languages, using the GeneratedSeparate policy and
+// GOMAXPROCS workers. To add or change languages start from NewCounter, or
+// set Languages to DefaultLanguages, as the nil Languages of the zero value
+// can't be changed. A Counter must not be changed while it's counting.
+type Counter struct {
+	// Languages that are detected, files in other languages are not counted.
+	// If nil the builtin languages are used.
+	Languages Languages
+	// Policy for generated, minified and binary files, one of
+	// GeneratedSeparate, GeneratedSkip or GeneratedCount.
+	Generated string
+	// Number of files counted in parallel by CountFS.
+	Workers int
+	// Skip is called by CountFS for all files and directories, except the
+	// root, if it returns true the file or the entire directory is not
+	// counted. If nil all files are counted.
+	Skip func(path string, d fs.DirEntry) bool
+	// CountFile is called by CountFS to count each regular file, e.g. to
+	// cache the results, it must be safe for concurrent use and the returned
+	// error should hold the path. If nil the file is opened in the file system
+	// and counted using Count.
+	CountFile func(fsys fs.FS, path string) (Result, error)
+}
+
+// NewCounter returns a new counter with a copy of the builtin languages, see
+// DefaultLanguages.
+func NewCounter() *Counter {
+	return &Counter{
+		Languages: DefaultLanguages(),
+		Generated: GeneratedSeparate,
+		Workers:   runtime.GOMAXPROCS(0),
+	}
+}
+
+// Source is the contents of a file, read at the start and end to detect the
+// language and then read sequentially to count the lines.
+type Source interface {
+	io.Reader
+	io.ReaderAt
+}
+
+// LineFunc is called for each line of a source file, without the new line,
+// with the name of the language of the line and whether the line has any
+// code and whether it has any comments.
+type LineFunc func(name string, line []byte, hasCode, hasComment bool)
+
+// Result is the result of counting a single file.
+type Result struct {
+	// Path of the file.
+	Path string
+	// Name of the language of the file, or an empty string if the file isn't
+	// counted. Using the GeneratedSeparate policy the kind is added, e.g.
+	// "go (generated)".
+	Language string
+	// Kind of the file, e.g. KindGenerated, see Counter.Generated.
+	Kind string
+	// Counts of the file, the language of the file has a file count of one.
+	// The counts can hold other languages embedded in the file, e.g. the
+	// javascript in a html file, without a file count.
+	Counts Counts
+	// Error counting the file, only set by CountFS.
+	Err error
+}
+
+func (c *Counter) languages() Languages {
+	if c.Languages == nil {
+		return builtin
+	}
+	return c.Languages
+}
+
+func (c *Counter) workers() int {
+	if c.Workers < 1 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return c.Workers
+}
+
+// Count counts the number of blank, comment and code lines in the contents of
+// the file at path, which must be read from the start, size is the size of
+// the file. A file in an unknown language is not counted, but it doesn't
+// return an error either.
+func (c *Counter) Count(path string, r Source, size int64) (Result, error) {
+	name, lang := c.languages().Detect(path, r, size)
+	return c.count(path, name, lang, r)
+}
+
+// CountAs counts the file like Count, but as the language with the name or
+// alias instead of detecting the language. It returns an error if there is
+// no such language.
+func (c *Counter) CountAs(path, alias string, r Source) (Result, error) {
+	name, lang := c.languages().ByAlias(alias)
+	if lang == nil {
+		return Result{Path: path}, fmt.Errorf("unknown language %s", alias)
+	}
+	return c.count(path, name, lang, r)
+}
+
+func (c *Counter) count(path, name string, lang *Language, r Source) (Result, error) {
+	result := Result{Path: path, Counts: Counts{}}
+	name, kind, err := c.lines(path, name, lang, r, func(name string, line []byte, hasCode, hasComment bool) {
+		if result.Counts[name] == nil {
+			result.Counts[name] = &Stats{}
+		}
+		result.Counts[name].AddLine(hasCode, hasComment)
+	})
+	if err != nil {
+		return Result{Path: path}, err
+	} else if name == "" {
+		return Result{Path: path, Counts: Counts{}}, nil
+	}
+
+	if result.Counts[name] == nil {
+		result.Counts[name] = &Stats{}
+	}
+	result.Counts[name].Files = 1
+	result.Language, result.Kind = name, kind
+	return result, nil
+}
+
+// Lines detects the language of the file at path and calls fn for each line
+// of the file, see Count. It returns the name of the language of the file,
+// or an empty string if the file isn't counted.
+//
+// Binary files are never lexed, see fileKind.
+func (c *Counter) Lines(path string, r Source, size int64, fn LineFunc) (string, error) {
+	name, lang := c.languages().Detect(path, r, size)
+	name, _, err := c.lines(path, name, lang, r, fn)
+	return name, err
+}
+
+// Lines lexes the file in the language, see Lines. It returns the name of
+// the language under which the file is counted and the kind of the file.
+func (c *Counter) lines(path, name string, lang *Language, r Source, fn LineFunc) (string, string, error) {
+	// Not a source file so we don't count it.
+	if lang == nil {
+		return "", KindSource, nil
+	}
+
+	// Decode the file into UTF-8, see detectEncoding.
+	head := readAt(r, 0, detectSize)
+	enc := detectEncoding(head)
+
+	generated := c.Generated
+	if generated == "" {
+		generated = GeneratedSeparate
+	}
+
+	newEmbedder := embedders[name]
+	kind := fileKind(path, name, lang, enc, head)
+	switch {
+	case kind == KindSource:
+	case kind == KindBinary && generated == GeneratedSeparate:
+		return BinaryLanguage, kind, nil
+	case kind == KindBinary || generated == GeneratedSkip:
+		return "", kind, nil
+	case generated == GeneratedSeparate:
+		name += " (" + kind + ")"
+		newEmbedder = nil
+	}
+
+	if newEmbedder != nil {
+		err := lexEmbedded(enc.reader(r), name, lang, newEmbedder(c.languages()), fn)
+		return name, kind, err
+	}
+
+	err := lexLines(enc.reader(r), lang, func(line []byte, hasCode, hasComment bool) {
+		fn(name, line, hasCode, hasComment)
+	})
+	return name, kind, err
+}
+
+// Errors holds the errors of all files that couldn't be counted by CountFS.
+type Errors []error
+
+func (errs Errors) Error() string {
+	switch len(errs) {
+	case 0:
+		return "no errors"
+	case 1:
+		return errs[0].Error()
+	default:
+		return fmt.Sprintf("%s (and %d more errors)", errs[0], len(errs)-1)
+	}
+}
+
+// Add adds the error to the list, if the error is a list itself all errors
+// in that list are added. A nil error is ignored.
+func (errs *Errors) Add(err error) {
+	if err == nil {
+		return
+	} else if list, ok := err.(Errors); ok {
+		*errs = append(*errs, list...)
+	} else {
+		*errs = append(*errs, err)
+	}
+}
+
+// Err returns the list as error, or nil if the list is empty.
+func (errs Errors) Err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// CountFS counts all regular files in the directory root of fsys and its
+// subdirectories, or only root itself if it's a file. If fn is not nil it's
+// called with the result of every file, including the files that couldn't
+// be counted, from the goroutine calling CountFS.
+//
+// The files are counted by a fixed number of workers, see Counter.Workers.
+// Files and directories that can't be read don't stop the counting, instead
+// the counts of all other files are returned along with Errors holding the
+// errors of all failed files and directories. If ctx is done the counting
+// stops and the counts of the files counted so far are returned along with
+// the error of ctx.
+func (c *Counter) CountFS(ctx context.Context, fsys fs.FS, root string, fn func(Result)) (Counts, error) {
+	n := c.workers()
+
+	// Paths of the files to count and the results of counting them.
+	paths := make(chan string, n)
+	results := make(chan Result, n)
+
+	var errs Errors
+	go func() {
+		defer close(paths)
+		err := fs.WalkDir(fsys, root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				errs = append(errs, err)
+				return nil
+			} else if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			if path != root && c.Skip != nil && c.Skip(path, d) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+
+			if d.Type().IsRegular() {
+				paths <- path
+			}
+			return nil
+		})
+		if err != nil && err != ctx.Err() {
+			errs = append(errs, err)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				if ctx.Err() != nil {
+					continue
+				}
+
+				countFile := c.countFile
+				if c.CountFile != nil {
+					countFile = c.CountFile
+				}
+				result, err := countFile(fsys, path)
+				result.Err = err
+				results <- result
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Add all results together.
+	counts := Counts{}
+	var fileErrs Errors
+	for result := range results {
+		if fn != nil {
+			fn(result)
+		}
+
+		if result.Err != nil {
+			fileErrs = append(fileErrs, result.Err)
+			continue
+		}
+		counts.Add(result.Counts)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return counts, err
+	}
+
+	// The walk is done once the results channel is closed.
+	errs = append(errs, fileErrs...)
+	if len(errs) != 0 {
+		return counts, errs
+	}
+	return counts, nil
+}
+
+// CountFile counts a single file in fsys, see Count. The returned error
+// always holds the path.
+func (c *Counter) countFile(fsys fs.FS, path string) (Result, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return Result{Path: path}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return Result{Path: path}, err
+	}
+
+	// Not all file systems support reading at an offset, those files are read
+	// into memory.
+	r, ok := f.(Source)
+	if !ok {
+		content, err := io.ReadAll(f)
+		if err != nil {
+			return Result{Path: path}, &fs.PathError{Op: "read", Path: path, Err: err}
+		}
+		r = bytes.NewReader(content)
+	}
+
+	result, err := c.Count(path, r, info.Size())
+	if err != nil {
+		return result, &fs.PathError{Op: "count", Path: path, Err: err}
+	}
+	return result, nil
+}
@@ -0,0 +1,139 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package loc
+
+import (
+	"context"
+	"io/fs"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCount(t *testing.T) {
+	type test struct {
+		path     string
+		src      string
+		policy   string
+		expected Result
+	}
+
+	generated := "// Code generated by x. DO NOT EDIT.\npackage main\n"
+	tests := []test{
+		{"main.go", "package main\n\n// x\n", "", Result{Language: "go",
+			Counts: Counts{"go": {1, 1, 1, 1}}}},
+		{"README", "Text.\n", "", Result{Counts: Counts{}}},
+		{"script", "#!/bin/sh\necho\n", "", Result{Language: "shell",
+			Counts: Counts{"shell": {1, 0, 1, 1}}}},
+		{"x.html", "<script>\nvar a;\n</script>\n", "", Result{Language: "html",
+			Counts: Counts{"html": {1, 0, 0, 2}, "javascript (embedded in html)": {0, 0, 0, 1}}}},
+		{"gen.go", generated, "", Result{Language: "go (generated)", Kind: KindGenerated,
+			Counts: Counts{"go (generated)": {1, 0, 1, 1}}}},
+		{"gen.go", generated, GeneratedSkip, Result{Counts: Counts{}}},
+		{"gen.go", generated, GeneratedCount, Result{Language: "go", Kind: KindGenerated,
+			Counts: Counts{"go": {1, 0, 1, 1}}}},
+		{"x.c", "int x;\x00\x00", "", Result{Language: BinaryLanguage, Kind: KindBinary,
+			Counts: Counts{BinaryLanguage: {1, 0, 0, 0}}}},
+	}
+
+	for _, test := range tests {
+		c := Counter{Generated: test.policy}
+		r := strings.NewReader(test.src)
+		got, err := c.Count(test.path, r, r.Size())
+		test.expected.Path = test.path
+		if err != nil {
+			t.Errorf("Unexpected error counting %s: %s", test.path, err)
+		} else if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Expected Count(%s) to return %+v, got %+v", test.path,
+				test.expected, got)
+		}
+	}
+}
+
+func TestCountAs(t *testing.T) {
+	var c Counter
+	r := strings.NewReader("x = 1 # Comment\n\n")
+	got, err := c.CountAs("-", "py", r)
+	expected := Result{Path: "-", Language: "python", Counts: Counts{"python": {1, 1, 0, 1}}}
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	} else if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected CountAs to return %+v, got %+v", expected, got)
+	}
+
+	if _, err := c.CountAs("-", "unknown", r); err == nil {
+		t.Error("Expected an error for an unknown language")
+	}
+}
+
+func TestCountFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"src/main.go":       {Data: []byte("package main\n\n// x\nfunc main() {}\n")},
+		"src/lib/lib.c":     {Data: []byte("int x;\n")},
+		"src/vendor/v.go":   {Data: []byte("package v\n")},
+		"src/README":        {Data: []byte("Not counted.\n")},
+		"src/link.go":       {Data: []byte("main.go"), Mode: fs.ModeSymlink},
+		"other/not/counted": {Data: []byte("package x\n")},
+	}
+
+	c := NewCounter()
+	c.Workers = 2
+	c.Skip = func(path string, d fs.DirEntry) bool {
+		return d.IsDir() && d.Name() == "vendor"
+	}
+
+	var paths []string
+	got, err := c.CountFS(context.Background(), fsys, "src", func(r Result) {
+		paths = append(paths, r.Path)
+	})
+	expected := Counts{"go": {1, 1, 1, 2}, "c": {1, 0, 0, 1}}
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	} else if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected CountFS to return %v, got %v", expected, got)
+	}
+	if len(paths) != 3 {
+		t.Errorf("Expected the results of 3 files, got %v", paths)
+	}
+
+	// A single file.
+	got, err = c.CountFS(context.Background(), fsys, "src/lib/lib.c", nil)
+	if expected := (Counts{"c": {1, 0, 0, 1}}); err != nil || !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected CountFS to return %v, got %v, %v", expected, got, err)
+	}
+
+	// Missing files are returned as Errors.
+	_, err = c.CountFS(context.Background(), fsys, "missing", nil)
+	if errs, ok := err.(Errors); !ok || len(errs) != 1 {
+		t.Errorf("Expected a single error, got %v", err)
+	}
+
+	// Cancelled before counting any file.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	got, err = c.CountFS(ctx, fsys, "src", nil)
+	if err != context.Canceled || len(got) != 0 {
+		t.Errorf("Expected no counts and %v, got %v, %v", context.Canceled, got, err)
+	}
+
+	// Counting files using CountFile.
+	var mu sync.Mutex
+	counted := map[string]bool{}
+	c.CountFile = func(fsys fs.FS, path string) (Result, error) {
+		mu.Lock()
+		counted[path] = true
+		mu.Unlock()
+		return Result{Path: path, Counts: Counts{"x": {Files: 1}}}, nil
+	}
+	got, err = c.CountFS(context.Background(), fsys, "src", nil)
+	if expected := (Counts{"x": {Files: 3}}); err != nil || !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected CountFS to return %v, got %v, %v", expected, got, err)
+	}
+	if len(counted) != 3 || !counted["src/main.go"] {
+		t.Errorf("Expected CountFile to be called for 3 files, got %v", counted)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package loc
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// The number of bytes read from the start and end of a file to look for a
+// shebang and modelines.
+const detectSize = 4096
+
+// The number of lines at the start and end of a file that can hold a
+// modeline, the same as the default of Vim.
+const modelineLines = 5
+
+var (
+	// Vim modelines, e.g. "vim: set ft=python :" or "vi: syntax=ruby".
+	vimModeline = regexp.MustCompile(`(?:^|\s)(?:vi|vim|ex)(?:[<=>]?\d+)?:(?:.*?[\s:])?(?:ft|filetype|syn|syntax)=([\w+#-]+)`)
+
+	// Emacs modelines, e.g. "-*- mode: python -*-" or "-*- C++ -*-".
+	emacsModeline = regexp.MustCompile(`-\*-(.*?)-\*-`)
+
+	// Version numbers after interpreters, e.g. "python3.6".
+	interpreterVersion = regexp.MustCompile(`[\d.]+$`)
+)
+
+// Detect detects the language of a file, in the following order:
+//
+//  1. the exact file name, e.g. Makefile;
+//  2. a Vim or Emacs modeline, in the first or last 5 lines;
+//  3. the file extention;
+//  4. the interpreter in the shebang, e.g. #!/usr/bin/env python.
+//
+// R is used to read the start and end of the file, size is the size of the
+// file. The start and end are decoded first, see detectEncoding. It returns
+// the name of the language and the language itself, or a nil language if
+// the language is unknown.
+func (l Languages) Detect(path string, r io.ReaderAt, size int64) (string, *Language) {
+	if name, lang := l.byFilename(path); lang != nil {
+		return name, lang
+	}
+
+	head := readAt(r, 0, detectSize)
+	tail := head
+	if size > detectSize {
+		// Keep UTF-16 characters aligned.
+		tail = readAt(r, (size-detectSize)&^1, detectSize)
+	}
+
+	enc := detectEncoding(head)
+	head, tail = enc.decode(head), enc.decode(tail)
+
+	if alias := modeline(head, tail); alias != "" {
+		if name, lang := l.ByAlias(alias); lang != nil {
+			return name, lang
+		}
+	}
+
+	if name, lang := l.ByPath(path); lang != nil {
+		return name, lang
+	}
+
+	if alias := shebang(head); alias != "" {
+		if name, lang := l.ByAlias(alias); lang != nil {
+			return name, lang
+		}
+	}
+
+	return "", nil
+}
+
+// ByPath detects the language based on the file name or extention, see
+// Detect to also use the contents of the file. It returns the name of the
+// language and the language itself, or a nil language if the language is
+// unknown.
+func (l Languages) ByPath(path string) (string, *Language) {
+	if name, lang := l.byFilename(path); lang != nil {
+		return name, lang
+	}
+
+	// Get the extention from the path.
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+
+	// Check if it matches a known extention of one of the languages.
+	for name, lang := range l {
+		for _, langExt := range lang.Extentions {
+			if langExt == ext {
+				return name, lang
+			}
+		}
+	}
+	return "", nil
+}
+
+// ReadAt reads up to n bytes from r at offset, ignoring errors.
+func readAt(r io.ReaderAt, offset, n int64) []byte {
+	buf := make([]byte, n)
+	read, _ := r.ReadAt(buf, offset)
+	return buf[:read]
+}
+
+// ByFilename returns the language that has the exact file name of the path,
+// or nil if there is no such language.
+func (l Languages) byFilename(path string) (string, *Language) {
+	base := filepath.Base(path)
+	for name, lang := range l {
+		for _, filename := range lang.Filenames {
+			if filename == base {
+				return name, lang
+			}
+		}
+	}
+	return "", nil
+}
+
+// ByAlias returns the language with the name or alias, ignoring case, or nil
+// if there is no such language.
+func (l Languages) ByAlias(alias string) (string, *Language) {
+	alias = strings.ToLower(alias)
+	if lang, ok := l[alias]; ok {
+		return alias, lang
+	}
+
+	for name, lang := range l {
+		for _, a := range lang.Aliases {
+			if a == alias {
+				return name, lang
+			}
+		}
+	}
+	return "", nil
+}
+
+// Modeline returns the language set in a Vim or Emacs modeline in the first
+// lines of head or the last lines of tail, or an empty string if there is no
+// modeline.
+func modeline(head, tail []byte) string {
+	lines := firstLines(head, modelineLines)
+	lines = append(lines, lastLines(tail, modelineLines)...)
+
+	for _, line := range lines {
+		if m := vimModeline.FindSubmatch(line); m != nil {
+			return string(m[1])
+		}
+
+		if m := emacsModeline.FindSubmatch(line); m != nil {
+			if mode := emacsMode(string(m[1])); mode != "" {
+				return mode
+			}
+		}
+	}
+	return ""
+}
+
+// EmacsMode returns the mode in the variables of an Emacs modeline, either in
+// the form of "mode: python; other: value" or only "python".
+func emacsMode(vars string) string {
+	if !strings.Contains(vars, ":") {
+		return strings.TrimSpace(vars)
+	}
+
+	for _, v := range strings.Split(vars, ";") {
+		kv := strings.SplitN(v, ":", 2)
+		if len(kv) == 2 && strings.TrimSpace(kv[0]) == "mode" {
+			return strings.TrimSpace(kv[1])
+		}
+	}
+	return ""
+}
+
+// Shebang returns the name of the interpreter in the shebang on the first
+// line, without a version number, or an empty string if there is no shebang.
+func shebang(head []byte) string {
+	if !bytes.HasPrefix(head, []byte("#!")) {
+		return ""
+	}
+
+	line := head[2:]
+	if i := bytes.IndexByte(line, '\n'); i != -1 {
+		line = line[:i]
+	}
+
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return ""
+	}
+
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		// Skip the options of env, e.g. -S.
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+				interpreter = filepath.Base(field)
+				break
+			}
+		}
+	}
+
+	return interpreterVersion.ReplaceAllString(interpreter, "")
+}
+
+// FirstLines returns up to n lines from the start of b.
+func firstLines(b []byte, n int) [][]byte {
+	lines := bytes.SplitN(b, []byte("\n"), n+1)
+	if len(lines) > n {
+		lines = lines[:n]
+	}
+	return lines
+}
+
+// LastLines returns up to n lines from the end of b, ignoring the trailing
+// new line.
+func lastLines(b []byte, n int) [][]byte {
+	lines := bytes.Split(bytes.TrimSuffix(b, []byte("\n")), []byte("\n"))
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
@@ -0,0 +1,201 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package loc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShebang(t *testing.T) {
+	type test struct {
+		head     string
+		expected string
+	}
+
+	tests := []test{
+		{"", ""},
+		{"package main\n", ""},
+		{"# Comment\n#!/bin/sh\n", ""},
+		{"#!/bin/sh\n", "sh"},
+		{"#! /bin/bash -e\necho\n", "bash"},
+		{"#!/usr/bin/python3.6\n", "python"},
+		{"#!/usr/bin/env python3\n", "python"},
+		{"#!/usr/bin/env -S ruby -w\n", "ruby"},
+		{"#!/usr/bin/env PATH=/bin node\n", "node"},
+		{"#!/usr/bin/env\n", ""},
+		{"#!", ""},
+	}
+
+	for _, test := range tests {
+		if got := shebang([]byte(test.head)); got != test.expected {
+			t.Errorf("Expected shebang(%q) to return %q, got %q",
+				test.head, test.expected, got)
+		}
+	}
+}
+
+func TestModeline(t *testing.T) {
+	type test struct {
+		head     string
+		tail     string
+		expected string
+	}
+
+	lines := strings.Repeat("x\n", 10)
+	tests := []test{
+		{"", "", ""},
+		{"package main\n", "package main\n", ""},
+		{"# vim: set ft=python :\n", "", "python"},
+		{"// vim:ft=go\n", "", "go"},
+		{"/* vi: set filetype=c: */\n", "", "c"},
+		{"# vim600: syntax=ruby\n", "", "ruby"},
+		{"", lines + "# vim: set ts=4 ft=sh :\n", "sh"},
+		{"", "# vim: set ft=sh :\n" + lines, ""},
+		{lines + "# vim: set ft=sh :\n", "", ""},
+		{"# index: ft=python\n", "", ""},
+		{"// -*- C++ -*-\n", "", "C++"},
+		{"#!/bin/sh\n# -*- mode: python; tab-width: 4 -*-\n", "", "python"},
+		{"; -*- indent-tabs-mode: nil; mode: lisp -*-\n", "", "lisp"},
+		{"# -*- coding: utf-8 -*-\n", "", ""},
+	}
+
+	for _, test := range tests {
+		got := modeline([]byte(test.head), []byte(test.tail))
+		if got != test.expected {
+			t.Errorf("Expected modeline(%q, %q) to return %q, got %q",
+				test.head, test.tail, test.expected, got)
+		}
+	}
+}
+
+func TestByPath(t *testing.T) {
+	type test struct {
+		filepath string
+		expected *Language
+	}
+
+	tests := []test{
+		{"file.as", builtin["actionscript"]},
+		{"file.asa", builtin["asp"]},
+		{"file.asp", builtin["asp"]},
+		{"file.c", builtin["c"]},
+		{"file.h", builtin["c"]},
+		{"file.cs", builtin["c#"]},
+		{"file.c++", builtin["c++"]},
+		{"file.cpp", builtin["c++"]},
+		{"file.cp", builtin["c++"]},
+		{"file.cc", builtin["c++"]},
+		{"file.hh", builtin["c++"]},
+		{"file.clj", builtin["clojure"]},
+		{"file.css", builtin["css"]},
+		{"file.d", builtin["d"]},
+		{"file.di", builtin["d"]},
+		{"file.erl", builtin["erlang"]},
+		{"file.hrl", builtin["erlang"]},
+		{"file.go", builtin["go"]},
+		{"file.dot", builtin["dot"]},
+		{"file.DOT", builtin["dot"]},
+		{"file.groovy", builtin["groovy"]},
+		{"file.gvy", builtin["groovy"]},
+		{"file.hs", builtin["haskell"]},
+		{"file.html", builtin["html"]},
+		{"file.htm", builtin["html"]},
+		{"file.shtml", builtin["html"]},
+		{"file.xhtml", builtin["html"]},
+		{"file.phtml", builtin["html"]},
+		{"file.tmpl", builtin["html"]},
+		{"file.tpl", builtin["html"]},
+		{"file.java", builtin["java"]},
+		{"file.js", builtin["javascript"]},
+		{"file.jsx", builtin["javascript"]},
+		{"file.lisp", builtin["lisp"]},
+		{"file.cl", builtin["lisp"]},
+		{"file.l", builtin["lisp"]},
+		{"file.lua", builtin["lua"]},
+		{"file.m", builtin["objective-c"]},
+		{"file.mm", builtin["objective-c"]},
+		{"file.M", builtin["objective-c"]},
+		{"file.ml", builtin["ocaml"]},
+		{"file.mli", builtin["ocaml"]},
+		{"file.mll", builtin["ocaml"]},
+		{"file.pas", builtin["pascal"]},
+		{"file.p", builtin["pascal"]},
+		{"file.pl", builtin["perl"]},
+		{"file.pm", builtin["perl"]},
+		{"file.php", builtin["php"]},
+		{"file.py", builtin["python"]},
+		{"file.rpy", builtin["python"]},
+		{"file.cpy", builtin["python"]},
+		{"file.pyw", builtin["python"]},
+		{"file.r", builtin["r"]},
+		{"file.R", builtin["r"]},
+		{"file.s", builtin["r"]},
+		{"file.S", builtin["r"]},
+		{"file.rb", builtin["ruby"]},
+		{"file.rbx", builtin["ruby"]},
+		{"file.rjs", builtin["ruby"]},
+		{"file.rs", builtin["rust"]},
+		{"file.scala", builtin["scala"]},
+		{"file.sh", builtin["shell"]},
+		{"file.bash", builtin["shell"]},
+		{"file.zsh", builtin["shell"]},
+		{"file.sql", builtin["sql"]},
+		{"Makefile", builtin["make"]},
+		{"dir/GNUmakefile", builtin["make"]},
+		{"file.mk", builtin["make"]},
+		{"Dockerfile", builtin["dockerfile"]},
+		{"Rakefile", builtin["ruby"]},
+		{"Gemfile", builtin["ruby"]},
+		{"file.txt", nil},
+		{"somefile", nil},
+		{"go", nil},
+	}
+
+	for _, test := range tests {
+		_, lang := builtin.ByPath(test.filepath)
+
+		if lang != test.expected {
+			t.Errorf("Expected ByPath(%s) to return %v, got %v",
+				test.filepath, test.expected, lang)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	type test struct {
+		path     string
+		content  string
+		expected string
+	}
+
+	tests := []test{
+		// File name before modeline.
+		{"Makefile", "# vim: ft=python\n", "make"},
+		// Modeline before extention.
+		{"file.h", "// -*- C++ -*-\n", "c++"},
+		{"file.txt", "# vim: set ft=ruby :\n", "ruby"},
+		// Unknown modeline language is ignored.
+		{"file.go", "// vim: ft=unkown\n", "go"},
+		// Extention before shebang.
+		{"file.rb", "#!/usr/bin/env python\n", "ruby"},
+		{"script", "#!/usr/bin/env python\n", "python"},
+		{"script", "#!/usr/local/bin/node\n", "javascript"},
+		{"script", "#!/usr/bin/env runhaskell\n", "haskell"},
+		{"script", "#!/usr/bin/awk -f\n", ""},
+		{"script", "Some text\n", ""},
+	}
+
+	langs := DefaultLanguages()
+	for _, test := range tests {
+		r := strings.NewReader(test.content)
+		name, lang := langs.Detect(test.path, r, r.Size())
+
+		if name != test.expected || lang != langs[test.expected] {
+			t.Errorf("Expected Detect(%s, %q) to return %q, got %q",
+				test.path, test.content, test.expected, name)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package loc
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Embedder finds the regions of other languages embedded in a file of the
+// host language, e.g. <script> elements in html files.
+type embedder interface {
+	// Open is called for each line in the host language, outside of comments.
+	// If the line starts a region of an embedded language it returns the
+	// name of the language, the language itself and the index in the line at
+	// which the region starts. Otherwise it returns a nil language.
+	open(line []byte) (string, *Language, int)
+
+	// Close is called for each line inside a region. If the region ends in
+	// the line it returns the index at which the region ends, otherwise -1.
+	close(line []byte) int
+}
+
+// Embedders maps the names of host languages to a function that creates an
+// embedder for a single file, finding the embedded languages in langs.
+var embedders = map[string]func(langs Languages) embedder{
+	"html":     newTagEmbedder,
+	"vue":      newTagEmbedder,
+	"svelte":   newTagEmbedder,
+	"markdown": newFenceEmbedder,
+}
+
+// EmbeddedName returns the name under which the lines of the embedded
+// language are counted, e.g. "javascript (embedded in html)".
+func embeddedName(name, host string) string {
+	return name + " (embedded in " + host + ")"
+}
+
+// LexEmbedded lexes the lines read from r, like lexLines, but lines inside
+// regions of an embedded language are lexed as that language and passed to
+// fn with the name of the embedded language, see embeddedName.
+//
+// A line that is partly inside a region, e.g. the line holding the closing
+// tag, is counted as a code line of the embedded language if that part has
+// code. Otherwise it's counted in the host language, unless only the
+// embedded part has comments.
+func lexEmbedded(r io.Reader, host string, lang *Language, e embedder, fn LineFunc) error {
+	hostLexer := lexer{lang: lang}
+
+	var (
+		name     string // Name of the current embedded language.
+		embedded *lexer // Lexer of the current region, nil outside regions.
+	)
+
+	return readLines(r, func(line []byte) {
+		full := line
+		var hostCode, hostComment, code, comment bool
+		inRegion := embedded != nil
+
+		if embedded == nil && hostLexer.mode == modeCode {
+			if n, l, i := e.open(line); l != nil {
+				hostCode, hostComment = hostLexer.line(line[:i])
+				name, embedded, inRegion = embeddedName(n, host), &lexer{lang: l}, true
+				line = line[i:]
+			}
+		}
+
+		if embedded != nil {
+			end := e.close(line)
+			if end == -1 {
+				code, comment = embedded.line(line)
+				line = nil
+			} else {
+				code, comment = embedded.line(line[:end])
+				line = line[end:]
+				embedded = nil
+			}
+		}
+
+		if len(line) > 0 {
+			hasCode, hasComment := hostLexer.line(line)
+			hostCode, hostComment = hostCode || hasCode, hostComment || hasComment
+		}
+
+		if inRegion && (code || (!hostCode && (comment || !hostComment))) {
+			fn(name, full, code, comment)
+		} else {
+			fn(host, full, hostCode, hostComment)
+		}
+	})
+}
+
+// TagEmbedder finds <script> and <style> elements in html (and alike) files,
+// by default holding javascript and css. The language can be changed using
+// the lang or type attribute, e.g. <script lang="ts">. Elements holding an
+// unknown language are counted as part of the host language.
+//
+// Only elements of which the start tag is at the start of a line are found.
+type tagEmbedder struct {
+	langs Languages
+	end   []byte // The end tag of the current element, e.g. "</script".
+}
+
+func newTagEmbedder(langs Languages) embedder {
+	return &tagEmbedder{langs: langs}
+}
+
+var (
+	// Start tag of a script or style element, at the start of a line.
+	startTag = regexp.MustCompile(`(?i)^\s*<(script|style)\b([^>]*)>`)
+
+	// The lang or type attribute of a start tag.
+	langAttr = regexp.MustCompile(`(?i)\b(lang|type)\s*=\s*["']?([^"'\s>]+)`)
+)
+
+// The default languages of the elements.
+var tagLanguages = map[string]string{
+	"script": "javascript",
+	"style":  "css",
+}
+
+func (e *tagEmbedder) open(line []byte) (string, *Language, int) {
+	m := startTag.FindSubmatchIndex(line)
+	if m == nil {
+		return "", nil, 0
+	}
+
+	tag := strings.ToLower(string(line[m[2]:m[3]]))
+	alias := tagLanguages[tag]
+	if attr := langAttr.FindSubmatch(line[m[4]:m[5]]); attr != nil {
+		alias = mimeLanguage(string(attr[2]))
+	}
+
+	name, lang := e.langs.ByAlias(alias)
+	if lang == nil {
+		return "", nil, 0
+	}
+
+	e.end = []byte("</" + tag)
+	return name, lang, m[1]
+}
+
+func (e *tagEmbedder) close(line []byte) int {
+	return bytes.Index(bytes.ToLower(line), e.end)
+}
+
+// MimeLanguage returns the language alias of the value of a lang or type
+// attribute, e.g. "text/javascript" or "module" for javascript.
+func mimeLanguage(value string) string {
+	value = strings.ToLower(value)
+	if i := strings.LastIndexByte(value, '/'); i != -1 {
+		value = value[i+1:]
+	}
+	value = strings.TrimPrefix(value, "x-")
+
+	switch value {
+	case "module", "ecmascript", "babel", "jsx":
+		return "javascript"
+	case "tsx":
+		return "typescript"
+	}
+	return value
+}
+
+// FenceEmbedder finds fenced code blocks in markdown files, the language is
+// set by the first word of the info string, e.g. ```go. Code blocks without
+// a known language are counted as part of the host language.
+type fenceEmbedder struct {
+	langs Languages
+	fence []byte // The opening code fence of the current block, e.g. "```".
+}
+
+func newFenceEmbedder(langs Languages) embedder {
+	return &fenceEmbedder{langs: langs}
+}
+
+// Opening code fence, indented by at most 3 spaces, followed by the info
+// string.
+var codeFence = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})\\s*\\{?\\.?([^\\s`{}]*)")
+
+func (e *fenceEmbedder) open(line []byte) (string, *Language, int) {
+	m := codeFence.FindSubmatch(line)
+	if m == nil || len(m[2]) == 0 {
+		return "", nil, 0
+	}
+
+	name, lang := e.langs.ByAlias(string(m[2]))
+	if lang == nil {
+		return "", nil, 0
+	}
+
+	e.fence = append(e.fence[:0], m[1]...)
+	return name, lang, len(line)
+}
+
+func (e *fenceEmbedder) close(line []byte) int {
+	// A closing fence must be at least as long as the opening fence, using the
+	// same character, and can't have an info string.
+	trimmed := bytes.TrimSpace(line)
+	if len(line)-len(bytes.TrimLeft(line, " ")) <= 3 &&
+		len(trimmed) >= len(e.fence) &&
+		len(bytes.Trim(trimmed, string(e.fence[:1]))) == 0 {
+		return 0
+	}
+	return -1
+}
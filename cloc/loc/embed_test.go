@@ -0,0 +1,98 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package loc
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// ClassifyEmbedded counts the number of blank, comment and code lines read
+// from r, like Classify, but lines inside regions of an embedded language are
+// counted under that language, see lexEmbedded.
+//
+// The returned counts always hold the host language, without a file count.
+func classifyEmbedded(r io.Reader, host string, lang *Language, e embedder) (Counts, error) {
+	c := Counts{host: &Stats{}}
+	err := lexEmbedded(r, host, lang, e, func(name string, line []byte, hasCode, hasComment bool) {
+		if c[name] == nil {
+			c[name] = &Stats{}
+		}
+		c[name].AddLine(hasCode, hasComment)
+	})
+	return c, err
+}
+
+func TestClassifyEmbedded(t *testing.T) {
+	type test struct {
+		language string
+		src      string
+		expected Counts // Files is ignored.
+	}
+
+	tests := []test{
+		{"html", "<p>x</p>\n", Counts{"html": {0, 0, 0, 1}}},
+		{"html", "<script>\nvar a;\n\n// x\n</script>\n", Counts{
+			"html":                          {0, 0, 0, 2},
+			"javascript (embedded in html)": {0, 1, 1, 1},
+		}},
+		{"html", "<script>var a;</script>\n<p>x</p>\n", Counts{
+			"html":                          {0, 0, 0, 1},
+			"javascript (embedded in html)": {0, 0, 0, 1},
+		}},
+		{"html", "<SCRIPT TYPE='text/javascript'>\nvar a;\n</Script>\n", Counts{
+			"html":                          {0, 0, 0, 2},
+			"javascript (embedded in html)": {0, 0, 0, 1},
+		}},
+		{"html", "<script>\n// x</script> <!-- y -->\n", Counts{"html": {0, 0, 0, 2}}},
+		{"html", "<script>\n/* </script> */\n", Counts{"html": {0, 0, 0, 2}}},
+		{"html", "<script type=\"text/template\">\n<p>x</p>\n</script>\n",
+			Counts{"html": {0, 0, 0, 3}}},
+		{"html", "<!--\n<script>\nvar a;\n-->\n", Counts{"html": {0, 0, 4, 0}}},
+		{"html", "<style lang=\"less\">\na {}\n</style>\n", Counts{"html": {0, 0, 0, 3}}},
+		{"vue", "<script lang=\"ts\">\nlet a: number\n</script>\n", Counts{
+			"vue":                          {0, 0, 0, 2},
+			"typescript (embedded in vue)": {0, 0, 0, 1},
+		}},
+		{"markdown", "# Title\n\n```go\nx := 1\n```\n", Counts{
+			"markdown":                  {0, 1, 0, 3},
+			"go (embedded in markdown)": {0, 0, 0, 1},
+		}},
+		{"markdown", "````{.python}\n```\n# x\n````\n", Counts{
+			"markdown":                      {0, 0, 0, 2},
+			"python (embedded in markdown)": {0, 0, 1, 1},
+		}},
+		{"markdown", "~~~ruby\n```\n~~~~\nx\n", Counts{
+			"markdown":                    {0, 0, 0, 3},
+			"ruby (embedded in markdown)": {0, 0, 0, 1},
+		}},
+		{"markdown", "```text\nx\n```\n```\ny\n```\n", Counts{"markdown": {0, 0, 0, 6}}},
+		{"markdown", "    ```go\nx\n", Counts{"markdown": {0, 0, 0, 2}}},
+	}
+
+	langs := DefaultLanguages()
+	for _, test := range tests {
+		got, err := classifyEmbedded(strings.NewReader(test.src), test.language,
+			langs[test.language], embedders[test.language](langs))
+		if err != nil {
+			t.Errorf("Unexpected error: %s", err)
+			continue
+		}
+
+		if len(got) != len(test.expected) {
+			t.Errorf("Expected classifyEmbedded(%q) to return %v, got %v",
+				test.src, test.expected, got)
+			continue
+		}
+
+		for name, expected := range test.expected {
+			if got[name] == nil || *got[name] != *expected {
+				t.Errorf("Expected %s in %q to be %+v, got %+v", name, test.src,
+					expected, got[name])
+			}
+		}
+	}
+}
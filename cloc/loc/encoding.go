@@ -0,0 +1,231 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package loc
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is the character encoding of a source file, all encodings are
+// decoded into UTF-8 before lexing.
+type encoding int
+
+// Supported encodings.
+const (
+	encUTF8 encoding = iota
+	encUTF8BOM
+	encUTF16LE
+	encUTF16BE
+	encLatin1
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+func (enc encoding) String() string {
+	switch enc {
+	case encUTF8BOM:
+		return "utf-8 (bom)"
+	case encUTF16LE:
+		return "utf-16le"
+	case encUTF16BE:
+		return "utf-16be"
+	case encLatin1:
+		return "latin-1"
+	default:
+		return "utf-8"
+	}
+}
+
+// DetectEncoding detects the encoding based on the start of a file, in the
+// following order:
+//
+//  1. a byte order mark (BOM) for UTF-8 or UTF-16;
+//  2. UTF-16 without a BOM, if (almost) every other byte is NUL, as is the
+//     case for mostly ASCII text;
+//  3. UTF-8, if head is valid UTF-8 (ASCII included);
+//  4. Latin-1 (ISO 8859-1) otherwise.
+func detectEncoding(head []byte) encoding {
+	switch {
+	case bytes.HasPrefix(head, bomUTF8):
+		return encUTF8BOM
+	case bytes.HasPrefix(head, bomUTF16LE):
+		return encUTF16LE
+	case bytes.HasPrefix(head, bomUTF16BE):
+		return encUTF16BE
+	}
+
+	if enc, ok := detectUTF16(head); ok {
+		return enc
+	}
+
+	if validUTF8(head) {
+		return encUTF8
+	}
+	return encLatin1
+}
+
+// DetectUTF16 detects UTF-16 without a BOM, by counting the NUL bytes at the
+// even and odd positions. ASCII characters encoded in UTF-16 have a NUL as
+// the first (big endian) or second (little endian) byte.
+func detectUTF16(head []byte) (encoding, bool) {
+	pairs := len(head) / 2
+	if pairs == 0 {
+		return encUTF8, false
+	}
+
+	var even, odd int
+	for i := 0; i+1 < len(head); i += 2 {
+		if head[i] == 0 {
+			even++
+		}
+		if head[i+1] == 0 {
+			odd++
+		}
+	}
+
+	// At least half of the characters must be ASCII and the other position
+	// must (almost) never be NUL.
+	switch {
+	case odd*2 >= pairs && even*10 < pairs:
+		return encUTF16LE, true
+	case even*2 >= pairs && odd*10 < pairs:
+		return encUTF16BE, true
+	}
+	return encUTF8, false
+}
+
+// ValidUTF8 reports whether b is valid UTF-8, ignoring a character that is
+// cut off at the end of b.
+func validUTF8(b []byte) bool {
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if r == utf8.RuneError && size == 1 {
+			return !utf8.FullRune(b)
+		}
+		b = b[size:]
+	}
+	return true
+}
+
+// Reader returns a reader that decodes r, which must be at the start of the
+// file, into UTF-8. A byte order mark is removed.
+func (enc encoding) reader(r io.Reader) io.Reader {
+	switch enc {
+	case encUTF8BOM:
+		return &decoder{r: bufio.NewReader(r), next: readUTF8, skipBOM: true}
+	case encUTF16LE:
+		return &decoder{r: bufio.NewReader(r), next: readUTF16LE, skipBOM: true}
+	case encUTF16BE:
+		return &decoder{r: bufio.NewReader(r), next: readUTF16BE, skipBOM: true}
+	case encLatin1:
+		return &decoder{r: bufio.NewReader(r), next: readLatin1}
+	default:
+		return r
+	}
+}
+
+// Decode decodes b into UTF-8, it's used for the start and end of files when
+// detecting the language. A character cut off at the end of b is decoded as
+// utf8.RuneError.
+func (enc encoding) decode(b []byte) []byte {
+	if enc == encUTF8 {
+		return b
+	}
+
+	// Reading from a bytes.Reader never fails.
+	decoded, _ := ioutil.ReadAll(enc.reader(bytes.NewReader(b)))
+	return decoded
+}
+
+// Decoder is a reader that decodes runes read from r into UTF-8.
+type decoder struct {
+	r       *bufio.Reader
+	next    func(*bufio.Reader) (rune, error)
+	skipBOM bool   // Skip the first rune if it's a byte order mark.
+	buf     []byte // Decoded, but not yet read, bytes.
+	err     error
+}
+
+func (d *decoder) Read(p []byte) (int, error) {
+	for len(d.buf) < len(p) && d.err == nil {
+		var r rune
+		r, d.err = d.next(d.r)
+		if d.err != nil {
+			break
+		}
+
+		if d.skipBOM {
+			d.skipBOM = false
+			if r == '\uFEFF' {
+				continue
+			}
+		}
+
+		var b [utf8.UTFMax]byte
+		n := utf8.EncodeRune(b[:], r)
+		d.buf = append(d.buf, b[:n]...)
+	}
+
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	if n == 0 && d.err != nil {
+		return 0, d.err
+	}
+	return n, nil
+}
+
+func readUTF8(r *bufio.Reader) (rune, error) {
+	c, _, err := r.ReadRune()
+	return c, err
+}
+
+func readLatin1(r *bufio.Reader) (rune, error) {
+	b, err := r.ReadByte()
+	return rune(b), err
+}
+
+func readUTF16LE(r *bufio.Reader) (rune, error) {
+	return readUTF16(r, func(b []byte) uint16 { return uint16(b[0]) | uint16(b[1])<<8 })
+}
+
+func readUTF16BE(r *bufio.Reader) (rune, error) {
+	return readUTF16(r, func(b []byte) uint16 { return uint16(b[0])<<8 | uint16(b[1]) })
+}
+
+// ReadUTF16 reads a single UTF-16 encoded rune, including surrogate pairs.
+// Invalid or incomplete characters are returned as utf8.RuneError.
+func readUTF16(r *bufio.Reader, unit func([]byte) uint16) (rune, error) {
+	var b [2]byte
+	if n, err := io.ReadFull(r, b[:]); err == io.ErrUnexpectedEOF && n == 1 {
+		return utf8.RuneError, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	c := rune(unit(b[:]))
+	if !utf16.IsSurrogate(c) {
+		return c, nil
+	}
+
+	peek, err := r.Peek(2)
+	if err != nil {
+		return utf8.RuneError, nil
+	}
+
+	c = utf16.DecodeRune(c, rune(unit(peek)))
+	if c != utf8.RuneError {
+		r.Discard(2)
+	}
+	return c, nil
+}
@@ -0,0 +1,85 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package loc
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	type test struct {
+		head     string
+		expected encoding
+	}
+
+	tests := []test{
+		{"", encUTF8},
+		{"package main\n", encUTF8},
+		{"// héllo\n", encUTF8},
+		{"// h\xc3", encUTF8}, // Cut off character.
+		{"\xef\xbb\xbfpackage main\n", encUTF8BOM},
+		{"\xff\xfe/\x00/\x00", encUTF16LE},
+		{"\xfe\xff\x00/\x00/", encUTF16BE},
+		{"/\x00/\x00 \x00x\x00\n\x00", encUTF16LE},
+		{"\x00/\x00/\x00 \x00x\x00\n", encUTF16BE},
+		{"// h\xe9llo\n", encLatin1},
+		{"\x00\x00\x00\x00", encUTF8},
+	}
+
+	for _, test := range tests {
+		if got := detectEncoding([]byte(test.head)); got != test.expected {
+			t.Errorf("Expected detectEncoding(%q) to return %s, got %s",
+				test.head, test.expected, got)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	type test struct {
+		enc      encoding
+		src      string
+		expected string
+	}
+
+	tests := []test{
+		{encUTF8, "héllo", "héllo"},
+		{encUTF8BOM, "\xef\xbb\xbfhéllo", "héllo"},
+		{encUTF16LE, "\xff\xfeh\x00\xe9\x00", "hé"},
+		{encUTF16BE, "\xfe\xff\x00h\x00\xe9", "hé"},
+		{encUTF16LE, "h\x00=\xd8\x00\xde", "h\U0001F600"}, // Surrogate pair.
+		{encUTF16BE, "\x00h\xd8=\xde\x00", "h\U0001F600"},
+		{encUTF16LE, "h\x00=\xd8", "h" + string(utf8.RuneError)},
+		{encUTF16LE, "h\x00i", "h" + string(utf8.RuneError)},
+		{encLatin1, "h\xe9llo", "héllo"},
+	}
+
+	for _, test := range tests {
+		if got := string(test.enc.decode([]byte(test.src))); got != test.expected {
+			t.Errorf("Expected decoding %q as %s to return %q, got %q",
+				test.src, test.enc, test.expected, got)
+		}
+	}
+}
+
+func TestDecodeLong(t *testing.T) {
+	// Longer than the buffers of the decoder.
+	expected := strings.Repeat("héllo wörld\n", 10000)
+
+	var src []byte
+	for _, r := range expected {
+		src = append(src, byte(r), byte(r>>8))
+	}
+
+	got, err := ioutil.ReadAll(encUTF16LE.reader(strings.NewReader(string(src))))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	} else if string(got) != expected {
+		t.Errorf("Unexpected decoded output of %d bytes, expected %d bytes",
+			len(got), len(expected))
+	}
+}
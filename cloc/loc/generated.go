@@ -0,0 +1,124 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package loc
+
+import (
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Policies for generated, minified and binary files, see Counter.Generated.
+// Binary files are never counted as source files, using the separate policy
+// only the number of binary files is counted.
+const (
+	GeneratedSeparate = "separate" // Count them under a separate language.
+	GeneratedSkip     = "skip"     // Don't count them at all.
+	GeneratedCount    = "count"    // Count them as hand written files.
+)
+
+// Kinds of files that are not written by hand, see fileKind.
+const (
+	KindSource    = ""
+	KindGenerated = "generated"
+	KindMinified  = "minified"
+	KindBinary    = "binary"
+)
+
+// The name of the language under which binary files are counted, using the
+// separate policy.
+const BinaryLanguage = "binary"
+
+// Headers of generated files, matched on whole comment lines before the first
+// line of code, see generated. For example:
+//
+//	// Code generated by stringer -type=Kind; DO NOT EDIT.
+var generatedHeader = regexp.MustCompile(`^\W*(?:` +
+	`Code generated .* DO NOT EDIT\.?\W*$` + // Go and others.
+	`|<auto-generated\b` + // .NET.
+	`|@generated\b` +
+	`|Generated by .* DO NOT EDIT!?\W*$)`) // Protocol buffers.
+
+// The minimum average line length of minified files.
+const minifiedLineLength = 200
+
+// Languages of which files are minified, only files in these languages are
+// detected as minified by their line length. Long lines in other languages,
+// e.g. SQL or a table in Go, are normally written by hand.
+var minifiedLanguages = map[string]bool{
+	"css":        true,
+	"html":       true,
+	"javascript": true,
+}
+
+// FileKind detects whether the file in the language, with the name, is
+// generated, minified or binary, based on the path and the start of the file
+// (not yet decoded). Binary files have NUL bytes, or are not valid UTF-8 and
+// don't look like Latin-1 text either. Generated files have a header, see
+// generated. Minified files have ".min." in the file name or, in one of the
+// minifiedLanguages, very long lines.
+func fileKind(path, name string, lang *Language, enc encoding, head []byte) string {
+	text := enc.decode(head)
+	if bytes.IndexByte(text, 0) != -1 || (enc == encLatin1 && !latin1Text(head)) {
+		return KindBinary
+	}
+
+	if generated(text, lang) {
+		return KindGenerated
+	}
+
+	if strings.Contains(filepath.Base(path), ".min.") {
+		return KindMinified
+	} else if !minifiedLanguages[name] {
+		return KindSource
+	}
+
+	// Don't count the last line if there are others, it may not be complete.
+	length := len(text)
+	if lines := bytes.Count(text, []byte("\n")); lines != 0 {
+		length = bytes.LastIndexByte(text, '\n') / lines
+	}
+	if length > minifiedLineLength {
+		return KindMinified
+	}
+
+	return KindSource
+}
+
+// Generated reports whether the text has a comment line matching
+// generatedHeader. Like the Go convention only comment lines before the first
+// line of code are checked, so the header inside a string literal, or a
+// comment further down the file, doesn't make a file generated.
+func generated(text []byte, lang *Language) bool {
+	l := lexer{lang: lang}
+	var found, code bool
+	// Reading from a bytes.Reader never fails.
+	readLines(bytes.NewReader(text), func(line []byte) {
+		if found || code {
+			return
+		}
+
+		hasCode, hasComment := l.line(line)
+		if hasCode {
+			code = true
+		} else if hasComment && generatedHeader.Match(line) {
+			found = true
+		}
+	})
+	return found
+}
+
+// Latin1Text reports whether b looks like Latin-1 text, which has almost no
+// control characters other than white space.
+func latin1Text(b []byte) bool {
+	var control int
+	for _, c := range b {
+		if (c < 0x20 && !isSpace(c) && c != '\n' && c != '\x1b') || c == 0x7f {
+			control++
+		}
+	}
+	return control*100 < len(b)
+}
@@ -0,0 +1,59 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package loc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFileKind(t *testing.T) {
+	type test struct {
+		path     string
+		head     string
+		expected string
+	}
+
+	long := strings.Repeat("var a=1;", 50)
+
+	tests := []test{
+		{"main.go", "package main\n", KindSource},
+		{"main.go", "// Code generated by stringer; DO NOT EDIT.\n\npackage main\n", KindGenerated},
+		{"main.go", "// Copyright\n\n// Code generated by hand. DO NOT EDIT.\npackage main\n", KindGenerated},
+		{"main.go", "// Code generated by protoc-gen-go. DO NOT EDIT.\r\n", KindGenerated},
+		{"main.go", "x := \"Code generated by x. DO NOT EDIT.\"\n", KindSource},
+		{"main.go", "/*\n * Code generated by x. DO NOT EDIT.\n */\npackage main\n", KindGenerated},
+		{"main.go", "package main\n\n// Code generated by x. DO NOT EDIT.\n", KindSource},
+		{"main.go", "// Header.\nconst h = \"// Code generated by %s. DO NOT EDIT.\"\n", KindSource},
+		{"main.go", "h := `\n// Code generated by x. DO NOT EDIT.\n`\n", KindSource},
+		{"main.go", "// Writes \"Code generated by x. DO NOT EDIT.\" as header.\n", KindSource},
+		{"file.cs", "// <auto-generated>\n", KindGenerated},
+		{"file.js", "/** @generated */\n", KindGenerated},
+		{"file.py", "# Generated by the protocol buffer compiler.  DO NOT EDIT!\n", KindGenerated},
+		{"file.js", "// The @generatedFiles option.\n", KindSource},
+		{"app.min.js", "var a;\n", KindMinified},
+		{"file.js", long, KindMinified},
+		{"file.js", long + "\n" + long + "\n", KindMinified},
+		{"file.js", long + "\n" + strings.Repeat("x\n", 10), KindSource},
+		{"file.css", strings.Repeat("a{b:c}", 50), KindMinified},
+		{"table.go", "var t = []string{" + strings.Repeat(`"abc", `, 50) + "}\n", KindSource},
+		{"data.sql", "INSERT INTO t VALUES " + strings.Repeat("(1, 'abc'), ", 30) + ";\n", KindSource},
+		{"file.c", "int main() {}\x00\x00\x00", KindBinary},
+		{"file.c", "\x7fELF\x02\x01\x01\x00", KindBinary},
+		{"file.c", "\xff\xd8\xff\xe0\x10\x02\x03\x04\x05\x06", KindBinary},
+		{"file.c", "// h\xe9llo\nint main() {}\n", KindSource},
+		{"file.cs", "\xff\xfe/\x00/\x00 \x00x\x00", KindSource},
+	}
+
+	for _, test := range tests {
+		head := []byte(test.head)
+		name, lang := builtin.ByPath(test.path)
+		got := fileKind(test.path, name, lang, detectEncoding(head), head)
+		if got != test.expected {
+			t.Errorf("Expected fileKind(%s, %q) to return %q, got %q",
+				test.path, test.head, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package loc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Config is the contents of a language definition file, for example:
+//
+//	{
+//		"languages": {
+//			"mydsl": {
+//				"extensions": ["dsl"],
+//				"filenames": ["Dslfile"],
+//				"aliases": ["dsl"],
+//				"line_comments": ["--"],
+//				"block_comments": [{"start": "{-", "end": "-}", "nested": true}],
+//				"strings": [{"start": "\"", "end": "\"", "multiline": true}]
+//			}
+//		}
+//	}
+//
+// The field names of a language are defined by the language and delimiter
+// types.
+type config struct {
+	Languages map[string]*Language `json:"languages"`
+}
+
+// Load reads the language definitions, in the format of config, from r and
+// merges them with the languages in the registry, see Merge. The registry
+// must not be nil.
+func (l Languages) Load(r io.Reader) error {
+	var c config
+	if err := json.NewDecoder(r).Decode(&c); err != nil {
+		return err
+	}
+
+	for name, def := range c.Languages {
+		if err := l.Merge(name, def); err != nil {
+			return fmt.Errorf("language %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// Merge merges the language definition with the language in the registry
+// with the same name, if any. Fields that are set in the definition (even if
+// empty) replace the fields of the known language, other fields are kept.
+//
+// Extentions, file names and aliases of the definition are removed from all
+// other languages, so the definition always takes precedence.
+func (l Languages) Merge(name string, def *Language) error {
+	if l == nil {
+		return errors.New("can't change nil languages, see DefaultLanguages")
+	} else if name == "" {
+		return errors.New("invalid name")
+	} else if def == nil {
+		return errors.New("missing definition")
+	}
+
+	// Never modify a known language in place, others may still use it.
+	var lang Language
+	if known, ok := l[name]; ok {
+		lang = *known
+	}
+
+	if def.LineComments != nil {
+		lang.LineComments = def.LineComments
+	}
+	if def.BlockComments != nil {
+		lang.BlockComments = def.BlockComments
+	}
+	if def.Strings != nil {
+		lang.Strings = def.Strings
+	}
+	if def.Extentions != nil {
+		lang.Extentions = def.Extentions
+	}
+	if def.Filenames != nil {
+		lang.Filenames = def.Filenames
+	}
+	if def.Aliases != nil {
+		lang.Aliases = def.Aliases
+	}
+
+	if err := lang.validate(); err != nil {
+		return err
+	}
+
+	for otherName, other := range l {
+		if otherName == name {
+			continue
+		}
+
+		o := *other
+		o.Extentions = without(o.Extentions, lang.Extentions)
+		o.Filenames = without(o.Filenames, lang.Filenames)
+		o.Aliases = without(o.Aliases, lang.Aliases)
+		if len(o.Extentions) != len(other.Extentions) ||
+			len(o.Filenames) != len(other.Filenames) ||
+			len(o.Aliases) != len(other.Aliases) {
+			l[otherName] = &o
+		}
+	}
+
+	l[name] = &lang
+	return nil
+}
+
+// Validate checks if the language can be used by the lexer.
+func (lang *Language) validate() error {
+	for _, marker := range lang.LineComments {
+		if marker == "" {
+			return errors.New("empty line comment marker")
+		}
+	}
+
+	for _, delim := range lang.BlockComments {
+		if delim.Start == "" || delim.End == "" {
+			return errors.New("block comments need a start and end marker")
+		}
+	}
+
+	for _, delim := range lang.Strings {
+		if delim.Start == "" || delim.End == "" {
+			return errors.New("strings need a start and end marker")
+		}
+	}
+	return nil
+}
+
+// Without returns the items that are not in remove, as a new slice.
+func without(items, remove []string) []string {
+	var result []string
+outer:
+	for _, item := range items {
+		for _, r := range remove {
+			if item == r {
+				continue outer
+			}
+		}
+		result = append(result, item)
+	}
+	return result
+}
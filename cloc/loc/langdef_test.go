@@ -0,0 +1,111 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package loc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	def := `{
+	"languages": {
+		"mydsl": {
+			"extensions": ["dsl"],
+			"filenames": ["Dslfile"],
+			"aliases": ["dsl"],
+			"line_comments": ["--"],
+			"block_comments": [{"start": "{-", "end": "-}", "nested": true}],
+			"strings": [{"start": "\"", "end": "\""}]
+		},
+		"c++": {
+			"extensions": ["cpp", "h"]
+		}
+	}
+}`
+
+	langs := DefaultLanguages()
+	oldCpp := langs["c++"]
+	if err := langs.Load(strings.NewReader(def)); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	type test struct {
+		filepath string
+		expected string
+	}
+
+	tests := []test{
+		{"file.dsl", "mydsl"},
+		{"Dslfile", "mydsl"},
+		{"file.h", "c++"},
+		{"file.c", "c"},
+		{"file.cpp", "c++"},
+		{"file.cc", ""},
+	}
+
+	for _, test := range tests {
+		if name, _ := langs.ByPath(test.filepath); name != test.expected {
+			t.Errorf("Expected ByPath(%s) to return %q, got %q",
+				test.filepath, test.expected, name)
+		}
+	}
+
+	if name, _ := langs.ByAlias("dsl"); name != "mydsl" {
+		t.Errorf("Expected alias dsl to be mydsl, got %s", name)
+	}
+
+	// Fields not in the definition are kept, without modifying the original.
+	cpp := langs["c++"]
+	if cpp == oldCpp || len(cpp.LineComments) != 1 || cpp.LineComments[0] != "//" {
+		t.Errorf("Expected the c++ comments to be kept, got %+v", cpp)
+	}
+	if len(oldCpp.Extentions) != 5 || len(builtin["c++"].Extentions) != 5 {
+		t.Errorf("Expected the original c++ language not to be modified, got %+v",
+			oldCpp)
+	}
+	if _, ok := builtin["mydsl"]; ok {
+		t.Error("Expected the builtin languages not to be modified")
+	}
+
+	src := "{- a {- b -} -}\nx = \"--\" -- Comment\n"
+	expected := Stats{0, 0, 1, 1}
+	if got := classifyLines([]byte(src), langs["mydsl"]); got != expected {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	type test struct {
+		def      string
+		expected string
+	}
+
+	tests := []test{
+		{`{`, "unexpected EOF"},
+		{`{"languages": {"": {}}}`, "language : invalid name"},
+		{`{"languages": {"x": null}}`, "language x: missing definition"},
+		{`{"languages": {"x": {"line_comments": [""]}}}`,
+			"language x: empty line comment marker"},
+		{`{"languages": {"x": {"block_comments": [{"start": "/*"}]}}}`,
+			"language x: block comments need a start and end marker"},
+		{`{"languages": {"x": {"strings": [{"end": "'"}]}}}`,
+			"language x: strings need a start and end marker"},
+	}
+
+	for _, test := range tests {
+		err := DefaultLanguages().Load(strings.NewReader(test.def))
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("Expected error %q for %s, got %v", test.expected, test.def, err)
+		}
+	}
+
+	// The languages of the zero Counter.
+	var c Counter
+	err := c.Languages.Load(strings.NewReader(`{"languages": {"x": {}}}`))
+	if expected := "language x: can't change nil languages, see DefaultLanguages"; err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q for nil languages, got %v", expected, err)
+	}
+}
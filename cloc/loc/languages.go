@@ -0,0 +1,344 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package loc
+
+// Comment markers and literals shared by many languages.
+var (
+	lineC     = []string{"//"}
+	lineShell = []string{"#"}
+	blockC    = []Delimiter{{Start: "/*", End: "*/"}}
+
+	blockNestedC = []Delimiter{{Start: "/*", End: "*/", Nested: true}}
+
+	doubleQuote = Delimiter{Start: `"`, End: `"`}
+	singleQuote = Delimiter{Start: "'", End: "'"}
+	stringsC    = []Delimiter{doubleQuote, singleQuote}
+)
+
+// Language holds the definition of a language, the json field names are used
+// in language definition files, see Languages.Load.
+type Language struct {
+	// Markers of comments until the end of the line.
+	LineComments []string `json:"line_comments"`
+	// Markers of block comments.
+	BlockComments []Delimiter `json:"block_comments"`
+	// Markers of string and character literals.
+	Strings []Delimiter `json:"strings"`
+	// Known file extentions for the language.
+	Extentions []string `json:"extensions"`
+	// Known file names, e.g. Makefile.
+	Filenames []string `json:"filenames"`
+	// Names used in modelines and shebangs.
+	Aliases []string `json:"aliases"`
+}
+
+// Languages is a registry of languages by name, the names are used in Counts.
+type Languages map[string]*Language
+
+// DefaultLanguages returns the builtin languages. The returned registry is a
+// deep copy, it and the languages in it can be changed without changing the
+// builtin languages, see Languages.Load.
+func DefaultLanguages() Languages {
+	langs := make(Languages, len(builtin))
+	for name, lang := range builtin {
+		langs[name] = lang.clone()
+	}
+	return langs
+}
+
+// Clone returns a deep copy of the language.
+func (lang *Language) clone() *Language {
+	return &Language{
+		LineComments:  append([]string(nil), lang.LineComments...),
+		BlockComments: append([]Delimiter(nil), lang.BlockComments...),
+		Strings:       append([]Delimiter(nil), lang.Strings...),
+		Extentions:    append([]string(nil), lang.Extentions...),
+		Filenames:     append([]string(nil), lang.Filenames...),
+		Aliases:       append([]string(nil), lang.Aliases...),
+	}
+}
+
+// TODO(Thomas): better notation of the languages
+var builtin = Languages{
+	"actionscript": {
+		LineComments:  lineC,
+		BlockComments: blockC,
+		Strings:       stringsC,
+		Extentions:    []string{"as"},
+		Aliases:       []string{"as"},
+	},
+	"asp": {
+		LineComments: []string{"'"},
+		Strings:      []Delimiter{{Start: `"`, End: `"`, Raw: true}},
+		Extentions:   []string{"asa", "asp"},
+		Aliases:      []string{"vbscript"},
+	},
+	"c": {
+		LineComments:  lineC,
+		BlockComments: blockC,
+		Strings:       stringsC,
+		Extentions:    []string{"c", "h"},
+	},
+	"c#": {
+		LineComments:  lineC,
+		BlockComments: blockC,
+		Strings: []Delimiter{
+			{Start: `@"`, End: `"`, Raw: true, Multiline: true},
+			doubleQuote,
+			singleQuote,
+		},
+		Extentions: []string{"cs"},
+		Aliases:    []string{"cs", "csharp"},
+	},
+	"c++": {
+		LineComments:  lineC,
+		BlockComments: blockC,
+		Strings: []Delimiter{
+			{Start: `R"(`, End: `)"`, Raw: true, Multiline: true},
+			doubleQuote,
+			singleQuote,
+		},
+		Extentions: []string{"c++", "cpp", "cp", "cc", "hh"},
+		Aliases:    []string{"cpp", "cxx"},
+	},
+	"clojure": {
+		LineComments: []string{";"},
+		Strings:      []Delimiter{{Start: `"`, End: `"`, Multiline: true}},
+		Extentions:   []string{"clj"},
+		Aliases:      []string{"clj"},
+	},
+	"css": {
+		BlockComments: blockC,
+		Strings:       stringsC,
+		Extentions:    []string{"css"},
+	},
+	"d": {
+		LineComments: lineC,
+		BlockComments: []Delimiter{
+			{Start: "/*", End: "*/"},
+			{Start: "/+", End: "+/", Nested: true},
+		},
+		Strings: []Delimiter{
+			{Start: "`", End: "`", Raw: true, Multiline: true},
+			{Start: `"`, End: `"`, Multiline: true},
+			singleQuote,
+		},
+		Extentions: []string{"d", "di"},
+	},
+	"erlang": {
+		LineComments: []string{"%"},
+		Strings:      stringsC,
+		Extentions:   []string{"erl", "hrl"},
+		Aliases:      []string{"erl", "escript"},
+	},
+	"go": {
+		LineComments:  lineC,
+		BlockComments: blockC,
+		Strings: []Delimiter{
+			{Start: "`", End: "`", Raw: true, Multiline: true},
+			doubleQuote,
+			singleQuote,
+		},
+		Extentions: []string{"go"},
+		Aliases:    []string{"golang"},
+	},
+	"dot": {
+		LineComments:  []string{"//", "#"},
+		BlockComments: blockC,
+		Strings:       []Delimiter{doubleQuote},
+		Extentions:    []string{"dot", "DOT"},
+	},
+	"groovy": {
+		LineComments:  []string{"//", "#"},
+		BlockComments: blockC,
+		Strings: []Delimiter{
+			{Start: `"""`, End: `"""`, Multiline: true},
+			{Start: "'''", End: "'''", Multiline: true},
+			doubleQuote,
+			singleQuote,
+		},
+		Extentions: []string{"groovy", "gvy"},
+		Aliases:    []string{"gvy"},
+	},
+	"haskell": {
+		LineComments:  []string{"--"},
+		BlockComments: []Delimiter{{Start: "{-", End: "-}", Nested: true}},
+		Strings:       []Delimiter{doubleQuote},
+		Extentions:    []string{"hs"},
+		Aliases:       []string{"hs", "runhaskell", "runghc"},
+	},
+	"html": {
+		BlockComments: []Delimiter{{Start: "<!--", End: "-->"}},
+		Extentions:    []string{"html", "htm", "shtml", "xhtml", "phtml", "tmpl", "tpl"},
+	},
+	"java": {
+		LineComments:  lineC,
+		BlockComments: blockC,
+		Strings: []Delimiter{
+			{Start: `"""`, End: `"""`, Multiline: true},
+			doubleQuote,
+			singleQuote,
+		},
+		Extentions: []string{"java"},
+	},
+	"javascript": {
+		LineComments:  lineC,
+		BlockComments: blockC,
+		Strings: []Delimiter{
+			{Start: "`", End: "`", Multiline: true},
+			doubleQuote,
+			singleQuote,
+		},
+		Extentions: []string{"js", "jsx"},
+		Aliases:    []string{"js", "node", "nodejs"},
+	},
+	"lisp": {
+		LineComments:  []string{";"},
+		BlockComments: []Delimiter{{Start: "#|", End: "|#", Nested: true}},
+		Strings:       []Delimiter{{Start: `"`, End: `"`, Multiline: true}},
+		Extentions:    []string{"lisp", "cl", "l"},
+		Aliases:       []string{"common-lisp", "sbcl", "clisp"},
+	},
+	"lua": {
+		LineComments:  []string{"--"},
+		BlockComments: []Delimiter{{Start: "--[[", End: "]]"}},
+		Strings: []Delimiter{
+			{Start: "[[", End: "]]", Raw: true, Multiline: true},
+			doubleQuote,
+			singleQuote,
+		},
+		Extentions: []string{"lua"},
+	},
+	"markdown": {
+		BlockComments: []Delimiter{{Start: "<!--", End: "-->"}},
+		Extentions:    []string{"md", "markdown", "mdown", "mkd"},
+		Aliases:       []string{"md"},
+	},
+	"objective-c": {
+		LineComments:  lineC,
+		BlockComments: blockC,
+		Strings:       stringsC,
+		Extentions:    []string{"m", "mm", "M"},
+		Aliases:       []string{"objc"},
+	},
+	"ocaml": {
+		BlockComments: []Delimiter{{Start: "(*", End: "*)", Nested: true}},
+		Strings:       []Delimiter{{Start: `"`, End: `"`, Multiline: true}},
+		Extentions:    []string{"ml", "mli", "mll"},
+		Aliases:       []string{"tuareg"},
+	},
+	"pascal": {
+		LineComments:  lineC,
+		BlockComments: []Delimiter{{Start: "(*", End: "*)"}, {Start: "{", End: "}"}},
+		Strings:       []Delimiter{{Start: "'", End: "'", Raw: true}},
+		Extentions:    []string{"pas", "p"},
+		Aliases:       []string{"delphi"},
+	},
+	"perl": {
+		LineComments:  lineShell,
+		BlockComments: []Delimiter{{Start: "^=", End: "^=cut"}},
+		Strings:       stringsC,
+		Extentions:    []string{"pl", "pm"},
+		Aliases:       []string{"cperl"},
+	},
+	"php": {
+		LineComments:  []string{"//", "#"},
+		BlockComments: blockC,
+		Strings:       stringsC,
+		Extentions:    []string{"php"},
+	},
+	"python": {
+		LineComments: lineShell,
+		BlockComments: []Delimiter{
+			{Start: `"""`, End: `"""`},
+			{Start: "'''", End: "'''"},
+		},
+		Strings:    stringsC,
+		Extentions: []string{"py", "rpy", "cpy", "pyw"},
+		Aliases:    []string{"py"},
+	},
+	"r": {
+		LineComments: lineShell,
+		Strings:      stringsC,
+		Extentions:   []string{"R", "r", "s", "S"},
+		Aliases:      []string{"rscript"},
+	},
+	"ruby": {
+		LineComments:  lineShell,
+		BlockComments: []Delimiter{{Start: "^=begin", End: "^=end"}},
+		Strings:       stringsC,
+		Extentions:    []string{"rb", "rbx", "rjs"},
+		Filenames:     []string{"Rakefile", "Gemfile", "Vagrantfile"},
+		Aliases:       []string{"rb"},
+	},
+	"rust": {
+		LineComments:  lineC,
+		BlockComments: blockNestedC,
+		Strings: []Delimiter{
+			{Start: `r#"`, End: `"#`, Raw: true, Multiline: true},
+			{Start: `"`, End: `"`, Multiline: true},
+			singleQuote,
+		},
+		Extentions: []string{"rs"},
+		Aliases:    []string{"rs"},
+	},
+	"scala": {
+		LineComments:  lineC,
+		BlockComments: blockNestedC,
+		Strings: []Delimiter{
+			{Start: `"""`, End: `"""`, Raw: true, Multiline: true},
+			doubleQuote,
+			singleQuote,
+		},
+		Extentions: []string{"scala"},
+	},
+	"shell": {
+		LineComments: lineShell,
+		Strings: []Delimiter{
+			{Start: `"`, End: `"`, Multiline: true},
+			{Start: "'", End: "'", Raw: true, Multiline: true},
+		},
+		Extentions: []string{"sh", "bash", "zsh"},
+		Aliases:    []string{"sh", "bash", "zsh", "dash", "ksh", "shell-script"},
+	},
+	"sql": {
+		LineComments:  []string{"--"},
+		BlockComments: blockC,
+		Strings:       []Delimiter{{Start: "'", End: "'", Raw: true}},
+		Extentions:    []string{"sql"},
+	},
+	"svelte": {
+		BlockComments: []Delimiter{{Start: "<!--", End: "-->"}},
+		Extentions:    []string{"svelte"},
+	},
+	"typescript": {
+		LineComments:  lineC,
+		BlockComments: blockC,
+		Strings: []Delimiter{
+			{Start: "`", End: "`", Multiline: true},
+			doubleQuote,
+			singleQuote,
+		},
+		Extentions: []string{"ts", "tsx", "mts", "cts"},
+		Aliases:    []string{"ts"},
+	},
+	"vue": {
+		BlockComments: []Delimiter{{Start: "<!--", End: "-->"}},
+		Extentions:    []string{"vue"},
+	},
+	"make": {
+		LineComments: lineShell,
+		Extentions:   []string{"mk", "mak"},
+		Filenames:    []string{"Makefile", "makefile", "GNUmakefile"},
+		Aliases:      []string{"makefile"},
+	},
+	"dockerfile": {
+		LineComments: lineShell,
+		Strings:      stringsC,
+		Extentions:   []string{"dockerfile"},
+		Filenames:    []string{"Dockerfile"},
+		Aliases:      []string{"docker"},
+	},
+}
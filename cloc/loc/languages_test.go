@@ -0,0 +1,99 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package loc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLanguages(t *testing.T) {
+	extentions := map[string]string{}
+	filenames := map[string]string{}
+	aliases := map[string]string{}
+
+	for name, lang := range builtin {
+		if len(lang.Extentions) == 0 {
+			t.Errorf("Expected language %s to have extentions", name)
+		}
+
+		for _, ext := range lang.Extentions {
+			if other, ok := extentions[ext]; ok {
+				t.Errorf("Extention %s is used by both %s and %s", ext, name, other)
+			}
+			extentions[ext] = name
+		}
+
+		for _, filename := range lang.Filenames {
+			if other, ok := filenames[filename]; ok {
+				t.Errorf("File name %s is used by both %s and %s", filename, name,
+					other)
+			}
+			filenames[filename] = name
+		}
+
+		for _, alias := range lang.Aliases {
+			if other, ok := aliases[alias]; ok {
+				t.Errorf("Alias %s is used by both %s and %s", alias, name, other)
+			} else if _, ok := builtin[alias]; ok {
+				t.Errorf("Alias %s of %s is the name of a language", alias, name)
+			} else if alias != strings.ToLower(alias) {
+				t.Errorf("Alias %s of %s must be lower case", alias, name)
+			}
+			aliases[alias] = name
+		}
+
+		for _, marker := range lang.LineComments {
+			if marker == "" || strings.ContainsAny(marker, " \t\n") {
+				t.Errorf("Invalid line comment marker %q for %s", marker, name)
+			}
+		}
+
+		for _, delim := range lang.BlockComments {
+			if delim.Start == "" || delim.End == "" {
+				t.Errorf("Block comment %+v for %s must have a start and end",
+					delim, name)
+			}
+
+			if delim.Raw || delim.Multiline {
+				t.Errorf("Block comment %+v for %s has string only options set",
+					delim, name)
+			}
+
+			// A line comment marker that starts with the block comment start
+			// marker would never be matched, e.g. {- and -- in Haskell.
+			for _, marker := range lang.LineComments {
+				if strings.HasPrefix(marker, delim.Start) {
+					t.Errorf("Line comment marker %q of %s is shadowed by block "+
+						"comment %q", marker, name, delim.Start)
+				}
+			}
+		}
+
+		for _, delim := range lang.Strings {
+			if delim.Start == "" || delim.End == "" {
+				t.Errorf("String %+v for %s must have a start and end", delim, name)
+			}
+
+			if delim.Nested {
+				t.Errorf("String %+v for %s can't be nested", delim, name)
+			}
+		}
+	}
+}
+
+func TestDefaultLanguages(t *testing.T) {
+	langs := DefaultLanguages()
+	if len(langs) != len(builtin) {
+		t.Fatalf("Expected %d languages, got %d", len(builtin), len(langs))
+	}
+
+	// Changing the copy doesn't change the builtin languages.
+	langs["go"].Extentions[0] = "changed"
+	langs["go"].BlockComments[0].Start = "changed"
+	if builtin["go"].Extentions[0] == "changed" || builtin["go"].BlockComments[0].Start == "changed" {
+		t.Error("Expected the builtin go language to not be changed")
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package loc
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// Delimiter is a pair of start and end markers of a block comment or a string
+// (or character) literal, e.g. /* and */.
+//
+// A marker prefixed with ^ only matches at the start of a line, e.g. =begin
+// in Ruby.
+type Delimiter struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// The comment can be nested, block comments only.
+	Nested bool `json:"nested,omitempty"`
+	// A backslash doesn't escape the next character, strings only.
+	Raw bool `json:"raw,omitempty"`
+	// The literal can span multiple lines, strings only.
+	Multiline bool `json:"multiline,omitempty"`
+}
+
+// lexer modes.
+const (
+	modeCode = iota
+	modeComment
+	modeString
+)
+
+// Lexer classifies the lines of a source file, keeping track of whether or
+// not it's inside a block comment or string literal across lines.
+type lexer struct {
+	lang  *Language
+	mode  int
+	delim Delimiter // The delimiter of the current comment or string.
+	depth int       // Depth of nested block comments.
+}
+
+// Classify counts the number of blank, comment and code lines read from r. A
+// line is a code line if it has anything other then white space outside of a
+// comment, a comment line if it only has comments and white space, otherwise
+// it's a blank line.
+//
+// Comment markers inside string and character literals are ignored, so
+// "http://" in a string doesn't start a comment.
+//
+// The source is read line by line, so only the longest line needs to fit in
+// memory.
+func Classify(r io.Reader, lang *Language) (Stats, error) {
+	var s Stats
+	err := lexLines(r, lang, func(line []byte, hasCode, hasComment bool) {
+		s.AddLine(hasCode, hasComment)
+	})
+	return s, err
+}
+
+// LexLines lexes the lines read from r, calling fn for each line with whether
+// the line has any code and whether it has any comments, see Classify.
+func lexLines(r io.Reader, lang *Language, fn func(line []byte, hasCode, hasComment bool)) error {
+	l := lexer{lang: lang}
+	return readLines(r, func(line []byte) {
+		hasCode, hasComment := l.line(line)
+		fn(line, hasCode, hasComment)
+	})
+}
+
+// ReadLines calls fn for each line read from r, without the new line. Lines
+// that don't fit in the buffer of the reader are accumulated.
+func readLines(r io.Reader, fn func(line []byte)) error {
+	br := bufio.NewReader(r)
+
+	var long []byte // Buffer for lines that don't fit in br.
+	for {
+		line, err := br.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			long = append(long, line...)
+			continue
+		} else if len(long) > 0 {
+			line = append(long, line...)
+			long = long[:0]
+		}
+
+		if len(line) > 0 {
+			fn(bytes.TrimSuffix(line, []byte("\n")))
+		}
+
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// Line lexes a single line, without the new line, and reports whether the
+// line has any code and whether it has any comments.
+func (l *lexer) line(line []byte) (hasCode, hasComment bool) {
+	for i := 0; i < len(line); {
+		switch l.mode {
+		case modeComment:
+			if n := l.match(line, i, l.delim.End); n > 0 {
+				if l.depth--; l.depth == 0 {
+					l.mode = modeCode
+				}
+				hasComment = true
+				i += n
+				continue
+			}
+
+			if l.delim.Nested {
+				if n := l.match(line, i, l.delim.Start); n > 0 {
+					l.depth++
+					hasComment = true
+					i += n
+					continue
+				}
+			}
+
+			if !isSpace(line[i]) {
+				hasComment = true
+			}
+			i++
+
+		case modeString:
+			hasCode = true
+			if !l.delim.Raw && line[i] == '\\' {
+				i += 2
+			} else if n := l.match(line, i, l.delim.End); n > 0 {
+				l.mode = modeCode
+				i += n
+			} else {
+				i++
+			}
+
+		default:
+			if isSpace(line[i]) {
+				i++
+				continue
+			}
+
+			if delim, n := l.matchDelim(line, i, l.lang.BlockComments); n > 0 {
+				l.mode, l.delim, l.depth = modeComment, delim, 1
+				hasComment = true
+				i += n
+				continue
+			}
+
+			for _, marker := range l.lang.LineComments {
+				if l.match(line, i, marker) > 0 {
+					// The rest of the line is a comment.
+					return hasCode, true
+				}
+			}
+
+			if delim, n := l.matchDelim(line, i, l.lang.Strings); n > 0 {
+				l.mode, l.delim = modeString, delim
+				hasCode = true
+				i += n
+				continue
+			}
+
+			hasCode = true
+			i++
+		}
+	}
+
+	// Single line strings can't continue on the next line, most likely the
+	// string wasn't terminated or we misdetected it, either way we start the
+	// next line fresh.
+	if l.mode == modeString && !l.delim.Multiline {
+		l.mode = modeCode
+	}
+
+	return hasCode, hasComment
+}
+
+// MatchDelim returns the first delimiter of which the start marker matches
+// the line at position i, and the length of the marker. If no marker matches
+// it returns 0 as length.
+func (l *lexer) matchDelim(line []byte, i int, delims []Delimiter) (Delimiter, int) {
+	for _, delim := range delims {
+		if n := l.match(line, i, delim.Start); n > 0 {
+			return delim, n
+		}
+	}
+	return Delimiter{}, 0
+}
+
+// Match returns the length of marker if the line at position i starts with
+// it, otherwise it returns 0. A marker prefixed with ^ only matches at the
+// start of the line.
+func (l *lexer) match(line []byte, i int, marker string) int {
+	if len(marker) > 1 && marker[0] == '^' {
+		if i != 0 {
+			return 0
+		}
+		marker = marker[1:]
+	}
+
+	if marker == "" || !bytes.HasPrefix(line[i:], []byte(marker)) {
+		return 0
+	}
+	return len(marker)
+}
+
+// IsSpace reports whether the byte is white space, null bytes are considered
+// white space aswell.
+func isSpace(b byte) bool {
+	switch b {
+	case ' ', '\t', '\r', '\v', '\f', '\x00':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package loc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// ClassifyLines counts the number of blank, comment and code lines in the
+// source, see Classify.
+func classifyLines(src []byte, lang *Language) Stats {
+	// Reading from a bytes.Reader never fails.
+	s, _ := Classify(bytes.NewReader(src), lang)
+	return s
+}
+
+func TestClassifyLines(t *testing.T) {
+	type test struct {
+		language string
+		src      string
+		expected Stats // Files is ignored.
+	}
+
+	tests := []test{
+		{"go", "", Stats{}},
+		{"go", "\n\n", Stats{0, 2, 0, 0}},
+		{"go", "// Comment\nfunc main() {}\n", Stats{0, 0, 1, 1}},
+		{"go", `url := "http://example.com"`, Stats{0, 0, 0, 1}},
+		{"go", "s := \"/*\"\nx := 1\n// */\n", Stats{0, 0, 1, 2}},
+		{"go", `s := "\"//"`, Stats{0, 0, 0, 1}},
+		{"go", "c := '\"' // Quote\n", Stats{0, 0, 0, 1}},
+		{"go", "s := `\n// Not a comment\n\n`\n", Stats{0, 1, 0, 3}},
+		{"go", "s := `\\` // Comment\n// Comment", Stats{0, 0, 1, 1}},
+		{"go", "/* a\n\n b */ x := 1\n", Stats{0, 1, 1, 1}},
+		{"c", "char *s = \"/* not a comment\";\nint x;\n", Stats{0, 0, 0, 2}},
+		{"c", "/* \"not a string */ int x;\n", Stats{0, 0, 0, 1}},
+		{"c", "s = \"unterminated\n// Comment\n", Stats{0, 0, 1, 1}},
+		{"c++", "s = R\"(\n/* raw */\n)\";\n", Stats{0, 0, 0, 3}},
+		{"javascript", "var url = 'http://example.com'; // Site\n", Stats{0, 0, 0, 1}},
+		{"javascript", "var s = `\n/* template */\n`\n", Stats{0, 0, 0, 3}},
+		{"python", "# Comment\nurl = \"http://x.com/#anchor\"\n", Stats{0, 0, 1, 1}},
+		{"python", "\"\"\"\nDocstring.\n\"\"\"\nx = '#'\n", Stats{0, 0, 3, 1}},
+		{"ruby", "=begin\ncomment\n=end\nx = 1\n", Stats{0, 0, 3, 1}},
+		{"ruby", "x = 1\n =begin\n", Stats{0, 0, 0, 2}},
+		{"shell", "echo '# not a comment'\n# Comment\n", Stats{0, 0, 1, 1}},
+		{"sql", "SELECT '/*' FROM x;\n", Stats{0, 0, 0, 1}},
+		{"pascal", "s := 'it''s {not} a comment';\n", Stats{0, 0, 0, 1}},
+		{"html", "<p>http://example.com</p>\n<!-- x -->\n", Stats{0, 0, 1, 1}},
+		{"rust", "/* a /* b */ c */ fn main() {}\n", Stats{0, 0, 0, 1}},
+		{"rust", "/* a /* b */\nfn main() {}\n*/\n", Stats{0, 0, 3, 0}},
+		{"c", "/* a /* b */\nint main() {}\n*/\n", Stats{0, 0, 1, 2}},
+		{"ocaml", "(* a (* b *)\nlet x = 1\n*)\nlet y = 2\n", Stats{0, 0, 3, 1}},
+		{"haskell", "{- {- -} -}\nx = 1 -- {-\ny = 2\n", Stats{0, 0, 1, 2}},
+		{"d", "/+ /+ +/ */ +/ int x;\n/* /* */ int y;\n", Stats{0, 0, 0, 2}},
+		{"lisp", "#| #| |# (+ 1 2)\n|#\n(+ 1 2)\n", Stats{0, 0, 2, 1}},
+		{"pascal", "{ (* }\nx := 1;\n", Stats{0, 0, 1, 1}},
+	}
+
+	for _, test := range tests {
+		got := classifyLines([]byte(test.src), builtin[test.language])
+
+		if got != test.expected {
+			t.Errorf("Expected classifyLines(%q) for %s to return %+v, got %+v",
+				test.src, test.language, test.expected, got)
+		}
+	}
+}
+
+func TestClassifyLongLines(t *testing.T) {
+	// Lines longer than the buffer of the reader.
+	long := strings.Repeat("x", 100000)
+	src := "/*" + long + "\n" + long + "*/ " + long + "\n\n// " + long
+
+	got, err := Classify(strings.NewReader(src), builtin["go"])
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if expected := (Stats{0, 1, 2, 1}); got != expected {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
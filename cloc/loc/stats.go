@@ -0,0 +1,92 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package loc
+
+import "sort"
+
+// Stats holds the number of files and the number of blank, comment and code
+// lines in those files.
+type Stats struct {
+	Files   int `json:"files"`
+	Blank   int `json:"blank"`
+	Comment int `json:"comment"`
+	Code    int `json:"code"`
+}
+
+// Add adds the numbers in other to the stats.
+func (s *Stats) Add(other Stats) {
+	s.Files += other.Files
+	s.Blank += other.Blank
+	s.Comment += other.Comment
+	s.Code += other.Code
+}
+
+// Sub subtracts the numbers in other from the stats.
+func (s *Stats) Sub(other Stats) {
+	s.Files -= other.Files
+	s.Blank -= other.Blank
+	s.Comment -= other.Comment
+	s.Code -= other.Code
+}
+
+// AddLine adds a single line to the stats, a line with code is a code line
+// even if it also has a comment.
+func (s *Stats) AddLine(hasCode, hasComment bool) {
+	switch {
+	case hasCode:
+		s.Code++
+	case hasComment:
+		s.Comment++
+	default:
+		s.Blank++
+	}
+}
+
+// Counts holds the stats per language, keyed by the name of the language as
+// used in Languages.
+type Counts map[string]*Stats
+
+// Add adds all stats in other to the counts.
+func (c Counts) Add(other Counts) {
+	for name, s := range other {
+		if _, ok := c[name]; !ok {
+			c[name] = &Stats{}
+		}
+		c[name].Add(*s)
+	}
+}
+
+// Total returns the sum of the stats of all languages.
+func (c Counts) Total() Stats {
+	var total Stats
+	for _, s := range c {
+		total.Add(*s)
+	}
+	return total
+}
+
+// LanguageStats holds the stats of a single language.
+type LanguageStats struct {
+	Language string `json:"language"`
+	Stats
+}
+
+// Sorted returns the stats of all languages, sorted by the number of code
+// lines. Languages with the same number of code lines are sorted by name to
+// keep the output stable.
+func (c Counts) Sorted() []LanguageStats {
+	sorted := make([]LanguageStats, 0, len(c))
+	for name, s := range c {
+		sorted = append(sorted, LanguageStats{name, *s})
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Code == sorted[j].Code {
+			return sorted[i].Language < sorted[j].Language
+		}
+		return sorted[i].Code > sorted[j].Code
+	})
+	return sorted
+}
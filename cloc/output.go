@@ -0,0 +1,236 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+// SchemaVersion is the version of the schema used in the json, csv and yaml
+// output. It must be incremented on every incompatible change to the output,
+// e.g. renaming or removing a field, adding fields is allowed.
+const schemaVersion = 1
+
+// Report holds the counts of all paths counted, it's the data written in all
+// output formats.
+type report struct {
+	Version   int                 `json:"version"`
+	Paths     []pathStats         `json:"paths"`
+	Languages []loc.LanguageStats `json:"languages"`
+	Total     loc.Stats           `json:"total"`
+	// Groups of identical files, only set using -dedup, see removeDuplicates.
+	Duplicates []duplicateGroup `json:"duplicates,omitempty"`
+
+	counts loc.Counts // Counts of all paths combined.
+}
+
+// PathStats holds the stats of a single path, per language.
+type pathStats struct {
+	Path      string              `json:"path"`
+	Languages []loc.LanguageStats `json:"languages"`
+	Total     loc.Stats           `json:"total"`
+}
+
+// Add adds the counts of a path to the report.
+func (r *report) add(path string, c loc.Counts) {
+	if r.counts == nil {
+		r.counts = loc.Counts{}
+	}
+	r.counts.Add(c)
+
+	r.Version = schemaVersion
+	r.Paths = append(r.Paths, pathStats{path, c.Sorted(), c.Total()})
+	r.Languages = r.counts.Sorted()
+	r.Total = r.counts.Total()
+}
+
+// Formats maps the name of a output format to the function that writes the
+// report in that format.
+var formats = map[string]func(io.Writer, report) error{
+	"table": writeTable,
+	"json":  writeJSON,
+	"csv":   writeCSV,
+	"yaml":  writeYAML,
+}
+
+// WriteTable writes the report as a human readable table, see printCounts,
+// followed by the duplicate files, see printDuplicates.
+func writeTable(w io.Writer, r report) error {
+	if err := printCounts(w, r.counts); err != nil || len(r.Duplicates) == 0 {
+		return err
+	}
+
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return err
+	}
+	return printDuplicates(w, r.Duplicates)
+}
+
+// PrintCounts writes a table with the stats of each language, sorted by the
+// number of code lines, followed by the total of all languages.
+func printCounts(w io.Writer, c loc.Counts) error {
+	const (
+		header    = "%-16s %8s %8s %8s %8s\n"
+		row       = "%-16s %8d %8d %8d %8d\n"
+		separator = "------------------------------------------------------\n"
+	)
+
+	ew := &errWriter{w: w}
+	ew.printf(header, "Language", "Files", "Blank", "Comment", "Code")
+	ew.printf(separator)
+	for _, s := range c.Sorted() {
+		ew.printf(row, s.Language, s.Files, s.Blank, s.Comment, s.Code)
+	}
+	ew.printf(separator)
+
+	total := c.Total()
+	ew.printf(row, "Total", total.Files, total.Blank, total.Comment, total.Code)
+	return ew.err
+}
+
+// PrintDuplicates writes a table with the stats of the files not counted per
+// group of duplicate files, followed by the files in the group, the first of
+// which is counted.
+func printDuplicates(w io.Writer, groups []duplicateGroup) error {
+	const (
+		header    = "%-16s %8s %8s %8s %8s\n"
+		row       = "%-16s %8d %8d %8d %8d\n"
+		separator = "------------------------------------------------------\n"
+	)
+
+	ew := &errWriter{w: w}
+	ew.printf(header, "Duplicates", "Files", "Blank", "Comment", "Code")
+	ew.printf(separator)
+	var total loc.Stats
+	for _, g := range groups {
+		s := g.Excluded
+		ew.printf(row, g.Language, s.Files, s.Blank, s.Comment, s.Code)
+		ew.printf("  %s (counted)\n", g.Files[0])
+		for _, path := range g.Files[1:] {
+			ew.printf("  %s\n", path)
+		}
+		total.Add(s)
+	}
+	ew.printf(separator)
+	ew.printf(row, "Total", total.Files, total.Blank, total.Comment, total.Code)
+	return ew.err
+}
+
+// WriteJSON writes the report as a single json object.
+func writeJSON(w io.Writer, r report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes the report as csv, with a header as the first record. Each
+// record holds the stats of a single language of a single path. A record
+// with an empty language holds the total of all languages and a record with
+// an empty path holds the stats of all paths combined. Duplicate files are
+// not included.
+func writeCSV(w io.Writer, r report) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"version", "path", "language", "files", "blank",
+		"comment", "code"})
+
+	record := func(path, language string, s loc.Stats) {
+		cw.Write([]string{strconv.Itoa(r.Version), path, language,
+			strconv.Itoa(s.Files), strconv.Itoa(s.Blank), strconv.Itoa(s.Comment),
+			strconv.Itoa(s.Code)})
+	}
+
+	for _, p := range r.Paths {
+		for _, s := range p.Languages {
+			record(p.Path, s.Language, s.Stats)
+		}
+		record(p.Path, "", p.Total)
+	}
+
+	for _, s := range r.Languages {
+		record("", s.Language, s.Stats)
+	}
+	record("", "", r.Total)
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteYAML writes the report as a yaml document, using the same structure
+// as the json output.
+func writeYAML(w io.Writer, r report) error {
+	ew := &errWriter{w: w}
+
+	ew.printf("version: %d\n", r.Version)
+	ew.printf("paths:\n")
+	for _, p := range r.Paths {
+		ew.printf("  - path: %s\n", strconv.Quote(p.Path))
+		writeYAMLLanguages(ew, "    ", p.Languages)
+		writeYAMLStats(ew, "    ", "total", p.Total)
+	}
+	writeYAMLLanguages(ew, "", r.Languages)
+	writeYAMLStats(ew, "", "total", r.Total)
+
+	if len(r.Duplicates) != 0 {
+		ew.printf("duplicates:\n")
+		for _, g := range r.Duplicates {
+			ew.printf("  - language: %s\n", strconv.Quote(g.Language))
+			ew.printf("    files:\n")
+			for _, path := range g.Files {
+				ew.printf("      - %s\n", strconv.Quote(path))
+			}
+			writeYAMLStats(ew, "    ", "lines", g.Lines)
+			writeYAMLStats(ew, "    ", "excluded", g.Excluded)
+		}
+	}
+	return ew.err
+}
+
+// WriteYAMLLanguages writes a yaml list of language stats, prefixing every
+// line with indent.
+func writeYAMLLanguages(ew *errWriter, indent string, languages []loc.LanguageStats) {
+	if len(languages) == 0 {
+		ew.printf("%slanguages: []\n", indent)
+		return
+	}
+
+	ew.printf("%slanguages:\n", indent)
+	for _, s := range languages {
+		ew.printf("%s  - language: %s\n", indent, strconv.Quote(s.Language))
+		writeYAMLFields(ew, indent+"    ", s.Stats)
+	}
+}
+
+// WriteYAMLStats writes the stats as a yaml mapping under key.
+func writeYAMLStats(ew *errWriter, indent, key string, s loc.Stats) {
+	ew.printf("%s%s:\n", indent, key)
+	writeYAMLFields(ew, indent+"  ", s)
+}
+
+// WriteYAMLFields writes the fields of the stats as yaml key-value pairs.
+func writeYAMLFields(ew *errWriter, indent string, s loc.Stats) {
+	ew.printf("%sfiles: %d\n", indent, s.Files)
+	ew.printf("%sblank: %d\n", indent, s.Blank)
+	ew.printf("%scomment: %d\n", indent, s.Comment)
+	ew.printf("%scode: %d\n", indent, s.Code)
+}
+
+// ErrWriter is a writer that remembers the first error, after which all
+// writes are dropped.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, a ...interface{}) {
+	if ew.err == nil {
+		_, ew.err = fmt.Fprintf(ew.w, format, a...)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+var update = flag.Bool("update", false, "Update the golden files")
+
+func TestFormats(t *testing.T) {
+	var r report
+	for _, path := range []string{"_testdata/testdata1", "_testdata/testdata2"} {
+		counts, err := count(path)
+		if err != nil {
+			t.Fatalf("Unexpected error counting %s: %s", path, err)
+		}
+		r.add(path, counts)
+	}
+
+	extentions := map[string]string{
+		"table": "txt",
+		"json":  "json",
+		"csv":   "csv",
+		"yaml":  "yaml",
+	}
+
+	for format, write := range formats {
+		var buf bytes.Buffer
+		if err := write(&buf, r); err != nil {
+			t.Errorf("Unexpected error writing %s: %s", format, err)
+			continue
+		}
+
+		golden := filepath.Join("_golden", "report."+extentions[format])
+		if *update {
+			if err := ioutil.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+				t.Fatalf("Unexpected error updating golden file: %s", err)
+			}
+			continue
+		}
+
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("Unexpected error reading golden file: %s", err)
+		}
+
+		if got := buf.String(); got != string(expected) {
+			t.Errorf("Expected the %s output to be '%s', got '%s'",
+				format, expected, got)
+		}
+	}
+}
+
+// FailingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestFormatsWriteError(t *testing.T) {
+	var r report
+	r.add("main.go", loc.Counts{"go": {Files: 1, Code: 1}})
+	r.Duplicates = []duplicateGroup{{Language: "go", Files: []string{"a.go", "b.go"}}}
+
+	for format, write := range formats {
+		if err := write(failingWriter{}, r); err == nil {
+			t.Errorf("Expected an error writing %s to a failing writer", format)
+		}
+	}
+}
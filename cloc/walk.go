@@ -0,0 +1,230 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+// Symbolic link policies, set using the -symlinks flag.
+const (
+	symlinksFollow = "follow" // Follow links, skipping links that create a loop.
+	symlinksSkip   = "skip"   // Don't count links at all.
+)
+
+// Warn reports a problem that doesn't stop the counting, for testing it can be
+// overwritten.
+var warn = func(format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", a...)
+}
+
+// Dir is a directory being counted, linked to the directory it was found in.
+type dir struct {
+	path   string
+	info   os.FileInfo
+	depth  int          // Zero for the directory the counting started in.
+	rules  *ignoreRules // Ignore rules that apply to the contents.
+	parent *dir
+}
+
+// NewDir creates a new directory found in the parent directory, which may be
+// nil.
+func newDir(path string, info os.FileInfo, parent *dir) *dir {
+	d := &dir{path: path, info: info, parent: parent}
+	if parent != nil {
+		d.depth = parent.depth + 1
+		d.rules = parent.rules
+	}
+	return d
+}
+
+// Loop returns the parent (or grandparent etc.) directory that is the same
+// directory as d, which means d is reached through a symbolic link loop. If
+// there is no such directory it returns nil.
+//
+// Directories are compared using os.SameFile, which on Unix compares the
+// device and inode numbers.
+func (d *dir) loop() *dir {
+	for ancestor := d.parent; ancestor != nil; ancestor = ancestor.parent {
+		if os.SameFile(ancestor.info, d.info) {
+			return ancestor
+		}
+	}
+	return nil
+}
+
+// TooDeep reports whether the contents of the directory are deeper than
+// allowed by the -max-depth flag.
+func (d *dir) tooDeep() bool {
+	return maxDepth >= 0 && d.depth >= maxDepth
+}
+
+// DirFS is the file system of a directory being counted, see newDirFS.
+type dirFS struct {
+	root string
+	fsys fs.FS
+}
+
+// NewDirFS returns the file system of the directory root. Unlike os.DirFS the
+// errors hold the path including root, and using the follow policy of the
+// -symlinks flag symbolic links are resolved in directory listings, so that
+// fs.WalkDir walks linked directories like any other directory. Links that
+// can't be resolved are listed as links.
+func newDirFS(root string) dirFS {
+	return dirFS{root, os.DirFS(root)}
+}
+
+func (d dirFS) Open(name string) (fs.File, error) {
+	f, err := d.fsys.Open(name)
+	return f, d.fixError(err)
+}
+
+func (d dirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(d.fsys, name)
+	if symlinks == symlinksFollow {
+		for i, entry := range entries {
+			if entry.Type()&fs.ModeSymlink == 0 {
+				continue
+			}
+
+			if info, err := fs.Stat(d.fsys, path.Join(name, entry.Name())); err == nil {
+				entries[i] = fs.FileInfoToDirEntry(info)
+			}
+		}
+	}
+	return entries, d.fixError(err)
+}
+
+// Path returns the path of the file with the slash separated name in the
+// file system.
+func (d dirFS) path(name string) string {
+	return filepath.Join(d.root, filepath.FromSlash(name))
+}
+
+// FixError replaces the name in a *fs.PathError with the path, see path.
+func (d dirFS) fixError(err error) error {
+	if pathErr, ok := err.(*fs.PathError); ok {
+		pathErr.Path = d.path(pathErr.Path)
+	}
+	return err
+}
+
+// Walker decides which files and directories found while walking a directory
+// are counted, based on the flags and the ignore rules, see skip. Errors are
+// collected and don't stop the walk.
+type walker struct {
+	fsys dirFS
+	dir  *dir // Directory of the last path passed to skip.
+	errs loc.Errors
+}
+
+// NewWalker returns a walker for the directory root, reading the ignore
+// rules of the directory. It returns nil if the directory is deeper than
+// allowed by the -max-depth flag.
+func newWalker(root string) (*walker, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+
+	d := newDir(root, info, nil)
+	if d.tooDeep() {
+		return nil, nil
+	} else if d.rules, err = readIgnoreRules(root, nil); err != nil {
+		return nil, err
+	}
+	return &walker{fsys: newDirFS(root), dir: d}, nil
+}
+
+// Skip reports whether the file or directory at the slash separated path,
+// relative to the root, should not be counted, it's used as
+// loc.Counter.Skip. Like fs.WalkDir the paths must be passed depth first.
+func (w *walker) skip(name string, entry fs.DirEntry) bool {
+	p := w.fsys.path(name)
+
+	// The directory holding the path is the last directory or one of its
+	// parents.
+	for w.dir.parent != nil && w.dir.path != filepath.Dir(p) {
+		w.dir = w.dir.parent
+	}
+
+	if entry.Type()&fs.ModeSymlink != 0 {
+		// Using the follow policy links are resolved by dirFS, so this link is
+		// broken.
+		if symlinks == symlinksFollow {
+			_, err := os.Stat(p)
+			w.errs.Add(err)
+		}
+		return true
+	}
+
+	if excluded(p, entry.IsDir(), w.dir.rules) {
+		return true
+	} else if !entry.IsDir() {
+		return false
+	}
+
+	info, err := os.Stat(p)
+	if err != nil {
+		w.errs.Add(err)
+		return true
+	}
+
+	d := newDir(p, info, w.dir)
+	if d.tooDeep() {
+		return true
+	} else if ancestor := d.loop(); ancestor != nil {
+		warn("symbolic link loop, %s is the same directory as %s, not counting it.",
+			d.path, ancestor.path)
+		return true
+	}
+
+	if d.rules, err = readIgnoreRules(p, d.rules); err != nil {
+		// Without the rules we would count ignored files, so it's better to not
+		// count the directory at all.
+		w.errs.Add(err)
+		return true
+	}
+	w.dir = d
+	return false
+}
+
+// WalkDir calls fn with the path of every regular file in the directory root
+// that countDir counts. Errors don't stop the walk, they are returned as
+// loc.Errors.
+func walkDir(root string, fn func(path string)) error {
+	w, err := newWalker(filepath.Clean(root))
+	if err != nil || w == nil {
+		return err
+	}
+
+	var errs loc.Errors
+	fs.WalkDir(w.fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			errs.Add(err)
+			return nil
+		} else if name != "." && w.skip(name, entry) {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if entry.Type().IsRegular() {
+			fn(w.fsys.path(name))
+		}
+		return nil
+	})
+
+	// Errors of the walk first, like countDir.
+	errs = append(w.errs, errs...)
+	return errs.Err()
+}
@@ -0,0 +1,146 @@
+// Copyright (C) 2015 Thomas de Zeeuw.
+//
+// Licensed onder the MIT license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Thomasdezeeuw/tools/cloc/loc"
+)
+
+func TestCountDirWalk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"a.go", "b/b.go", "b/c/c.go", "b/c/d/d.go"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte("package x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A loop back to the root directory and a link to a file.
+	err = os.Symlink(dir, filepath.Join(dir, "b", "c", "loop"))
+	if err != nil {
+		t.Skipf("Can't create symbolic link: %s", err)
+	}
+	err = os.Symlink(filepath.Join(dir, "a.go"), filepath.Join(dir, "b", "a.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var warnings []string
+	oldWarn := warn
+	warn = func(format string, a ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, a...))
+	}
+	defer func() {
+		warn = oldWarn
+		maxDepth, symlinks = -1, symlinksFollow
+	}()
+
+	type test struct {
+		maxDepth int
+		symlinks string
+		expected int // Number of files.
+		warnings int
+	}
+
+	tests := []test{
+		{-1, symlinksFollow, 5, 1},
+		{-1, symlinksSkip, 4, 0},
+		{0, symlinksFollow, 0, 0},
+		{1, symlinksFollow, 1, 0},
+		{2, symlinksFollow, 3, 0},
+		{3, symlinksFollow, 4, 0},
+		{4, symlinksFollow, 5, 1},
+		{5, symlinksFollow, 5, 1},
+	}
+
+	for _, test := range tests {
+		maxDepth, symlinks = test.maxDepth, test.symlinks
+		warnings = nil
+
+		got, err := countDir(dir)
+		if err != nil {
+			t.Errorf("Unexpected error: %s", err)
+			continue
+		}
+
+		if files := got.Total().Files; files != test.expected {
+			t.Errorf("Expected %d files, got %d (max-depth %d, symlinks %s)",
+				test.expected, files, test.maxDepth, test.symlinks)
+		}
+
+		if len(warnings) != test.warnings {
+			t.Errorf("Expected %d warnings, got %v (max-depth %d, symlinks %s)",
+				test.warnings, warnings, test.maxDepth, test.symlinks)
+		}
+	}
+}
+
+func TestCountDirWorkers(t *testing.T) {
+	defer func(n int) { workers = n }(workers)
+
+	for _, n := range []int{0, 1, 2, 16} {
+		workers = n
+
+		got, err := countDir("_testdata")
+		if err != nil {
+			t.Errorf("Unexpected error with %d workers: %s", n, err)
+			continue
+		}
+
+		if expected := (loc.Stats{Files: 67, Blank: 70, Comment: 103, Code: 253}); got.Total() != expected {
+			t.Errorf("Expected %+v with %d workers, got %+v", expected, n,
+				got.Total())
+		}
+	}
+}
+
+func BenchmarkCountDir(b *testing.B) {
+	dir, err := ioutil.TempDir("", "cloc")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A tree of 50 directories with 40 files each, using the test data as
+	// content.
+	src, err := ioutil.ReadFile(filepath.Join("_testdata", "file.go"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		sub := filepath.Join(dir, fmt.Sprintf("dir%d", i/10), fmt.Sprintf("dir%d", i))
+		if err := os.MkdirAll(sub, 0755); err != nil {
+			b.Fatal(err)
+		}
+
+		for j := 0; j < 40; j++ {
+			path := filepath.Join(sub, fmt.Sprintf("file%d.go", j))
+			if err := ioutil.WriteFile(path, src, 0644); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := countDir(dir); err != nil {
+			b.Fatal(err)
+		}
+	}
+}